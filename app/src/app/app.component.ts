@@ -6,8 +6,26 @@ import {FilterComponent} from './filter/filter.component';
 import {TableComponent} from './table/table.component';
 
 interface LogEntry {
+  id?: number;
   line: string;
   timestamp: string;
+  source?: string;
+  level?: string;
+  fields?: Record<string, string>;
+  repeat?: number;
+  last_timestamp?: string;
+}
+
+interface LogUpdate {
+  id: number;
+  repeat: number;
+  last_timestamp: string;
+}
+
+interface IngestionStatus {
+  state: 'running' | 'eof' | 'error';
+  error?: string;
+  updatedAt: string;
 }
 
 @Component({
@@ -20,6 +38,7 @@ interface LogEntry {
 export class AppComponent implements OnInit {
   title = 'app';
   logs: LogEntry[] = [];
+  status?: IngestionStatus;
 
   constructor(
     private sseClient: SseClient,
@@ -40,6 +59,15 @@ export class AppComponent implements OnInit {
           
           if (messageEvent.type === 'reset') {
             this.logs = [];
+          } else if (messageEvent.type === 'status') {
+            this.status = JSON.parse(messageEvent.data);
+          } else if (messageEvent.type === 'update') {
+            const update: LogUpdate = JSON.parse(messageEvent.data);
+            const logEntry = this.logs.find(log => log.id === update.id);
+            if (logEntry) {
+              logEntry.repeat = update.repeat;
+              logEntry.last_timestamp = update.last_timestamp;
+            }
           } else if (messageEvent.data) {
             const logEntry: LogEntry = JSON.parse(messageEvent.data);
             this.logs.unshift(logEntry);
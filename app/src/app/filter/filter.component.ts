@@ -11,10 +11,16 @@ import { HttpClient, HttpHeaders } from '@angular/common/http';
 })
 export class FilterComponent {
   filter: string = '';
+  source: string = '';
+  sources: string[] = [];
 
   constructor(private http: HttpClient) {}
 
+  loadSources() {
+    this.http.get<string[]>('/sources').subscribe(sources => this.sources = sources);
+  }
+
   updateFilter() {
-    this.http.post('/filter', { filter: this.filter }).subscribe();
+    this.http.post('/filter', { filter: this.filter, source: this.source }).subscribe();
   }
 } 
\ No newline at end of file
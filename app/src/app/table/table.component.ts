@@ -1,16 +1,21 @@
 import { Component, Input } from '@angular/core';
-import { NgFor } from '@angular/common';
+import { KeyValuePipe, NgFor } from '@angular/common';
 import { AnsiPipe } from './ansi.pipe';
 
 interface LogEntry {
   line: string;
   timestamp: string;
+  source?: string;
+  level?: string;
+  fields?: Record<string, string>;
+  repeat?: number;
+  last_timestamp?: string;
 }
 
 @Component({
   selector: 'app-table',
   standalone: true,
-  imports: [NgFor, AnsiPipe],
+  imports: [NgFor, KeyValuePipe, AnsiPipe],
   templateUrl: './table.component.html',
   styleUrls: ['./table.component.css']
 })
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/carlo-colombo/streamlog_go/logentry"
+	"github.com/klauspost/compress/zstd"
+)
+
+// segmentExt is the extension of the archived partitions, segments holding
+// their logs as zstd compressed NDJSON, the format of the exports.
+const segmentExt = ".ndjson.zst"
+
+// archive writes the logs of the partition starting at start to a segment,
+// then removes its database. The segment is written next to it first, so a
+// partition is never left half archived.
+func (p *partitions) archive(ctx context.Context, counters *storeCounters, start time.Time) error {
+	dbPath := p.path(start)
+	db, err := sql.Open("sqlite3", readOnlyDSN(dbPath))
+	if err != nil {
+		return fmt.Errorf("failed to open partition: %w", err)
+	}
+	defer db.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(dbPath), ".streamlog-archive-*"+segmentExt)
+	if err != nil {
+		return fmt.Errorf("failed to create segment: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	compressed, err := zstd.NewWriter(tmp, zstd.WithEncoderLevel(zstd.SpeedBetterCompression))
+	if err != nil {
+		return fmt.Errorf("failed to create segment: %w", err)
+	}
+	encoder := json.NewEncoder(compressed)
+
+	query := fmt.Sprintf(`
+			SELECT id, line, timestamp, source, level, fields, repeat, last_timestamp
+			FROM logs
+			WHERE id > ?
+			ORDER BY id ASC
+			LIMIT %d`, exportPageSize)
+
+	var lastID int64
+	for {
+		var page []logentry.Log
+		err := retry(ctx, counters, func(ctx context.Context) error {
+			var err error
+			page, err = queryLogs(ctx, db, query, lastID)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to read partition: %w", err)
+		}
+
+		for _, logLine := range page {
+			if err := encoder.Encode(logLine); err != nil {
+				return fmt.Errorf("failed to write segment: %w", err)
+			}
+		}
+		if len(page) < exportPageSize {
+			break
+		}
+		lastID = page[len(page)-1].ID
+	}
+
+	if err := compressed.Close(); err != nil {
+		return fmt.Errorf("failed to write segment: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return fmt.Errorf("failed to write segment: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write segment: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), p.segmentPath(start)); err != nil {
+		return fmt.Errorf("failed to write segment: %w", err)
+	}
+
+	db.Close()
+	return removeDatabase(dbPath)
+}
+
+// readSegment calls fn with the logs of the segment at path, in the order
+// they were stored.
+func readSegment(path string, fn func(logentry.Log) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open segment: %w", err)
+	}
+	defer file.Close()
+
+	decompressed, err := zstd.NewReader(file, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return fmt.Errorf("failed to read segment %s: %w", path, err)
+	}
+	defer decompressed.Close()
+
+	decoder := json.NewDecoder(decompressed)
+	for {
+		var logLine logentry.Log
+		err := decoder.Decode(&logLine)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read segment %s: %w", path, err)
+		}
+		if err := fn(logLine); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,140 @@
+// Package export writes logs in the formats they can be downloaded in.
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/carlo-colombo/streamlog_go/logentry"
+)
+
+// Formats lists the names accepted by NewWriter.
+var Formats = []string{"ndjson", "csv", "txt"}
+
+// Writer writes logs one at a time in a format.
+type Writer interface {
+	Write(l logentry.Log) error
+	// Flush writes any buffered data.
+	Flush() error
+	// ContentType is the media type of the format.
+	ContentType() string
+}
+
+// NewWriter returns a writer of format to w, stripANSI removes the ANSI
+// escape sequences from the lines.
+func NewWriter(format string, w io.Writer, stripANSI bool) (Writer, error) {
+	var writer Writer
+	switch format {
+	case "ndjson", "":
+		writer = ndjsonWriter{w: w}
+	case "csv":
+		writer = &csvWriter{w: csv.NewWriter(w)}
+	case "txt":
+		writer = txtWriter{w: w}
+	default:
+		return nil, fmt.Errorf("unknown export format %q, expected one of ndjson, csv, txt", format)
+	}
+	if stripANSI {
+		writer = stripWriter{writer}
+	}
+	return writer, nil
+}
+
+var ansiPattern = regexp.MustCompile(`\x1b\[[0-9;?]*[ -/]*[@-~]`)
+
+// StripANSI removes the ANSI escape sequences, like colors, from s.
+func StripANSI(s string) string {
+	return ansiPattern.ReplaceAllString(s, "")
+}
+
+type stripWriter struct {
+	Writer
+}
+
+func (w stripWriter) Write(l logentry.Log) error {
+	l.Line = StripANSI(l.Line)
+	return w.Writer.Write(l)
+}
+
+type ndjsonWriter struct {
+	w io.Writer
+}
+
+func (w ndjsonWriter) Write(l logentry.Log) error {
+	buffer := &bytes.Buffer{}
+	encoder := json.NewEncoder(buffer)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(l); err != nil {
+		return fmt.Errorf("failed to encode log: %w", err)
+	}
+	_, err := w.w.Write(buffer.Bytes())
+	return err
+}
+
+func (ndjsonWriter) Flush() error        { return nil }
+func (ndjsonWriter) ContentType() string { return "application/x-ndjson" }
+
+type csvWriter struct {
+	w             *csv.Writer
+	headerWritten bool
+}
+
+var csvHeader = []string{"timestamp", "source", "level", "line", "fields", "repeat", "last_timestamp"}
+
+func (w *csvWriter) Write(l logentry.Log) error {
+	if !w.headerWritten {
+		if err := w.w.Write(csvHeader); err != nil {
+			return err
+		}
+		w.headerWritten = true
+	}
+
+	var fields, repeat, lastTimestamp string
+	if len(l.Fields) > 0 {
+		data, err := json.Marshal(l.Fields)
+		if err != nil {
+			return fmt.Errorf("failed to encode fields: %w", err)
+		}
+		fields = string(data)
+	}
+	if l.Repeat > 0 {
+		repeat = strconv.Itoa(l.Repeat)
+		lastTimestamp = l.LastTimestamp.Format(time.RFC3339Nano)
+	}
+
+	return w.w.Write([]string{
+		l.Timestamp.Format(time.RFC3339Nano), l.Source, l.Level, l.Line, fields, repeat, lastTimestamp,
+	})
+}
+
+func (w *csvWriter) Flush() error {
+	w.w.Flush()
+	return w.w.Error()
+}
+
+func (*csvWriter) ContentType() string { return "text/csv" }
+
+// txtWriter writes a log per line, as the timestamp, the source when set and
+// the line.
+type txtWriter struct {
+	w io.Writer
+}
+
+func (w txtWriter) Write(l logentry.Log) error {
+	var err error
+	if l.Source != "" {
+		_, err = fmt.Fprintf(w.w, "%s [%s] %s\n", l.Timestamp.Format(time.RFC3339Nano), l.Source, l.Line)
+	} else {
+		_, err = fmt.Fprintf(w.w, "%s %s\n", l.Timestamp.Format(time.RFC3339Nano), l.Line)
+	}
+	return err
+}
+
+func (txtWriter) Flush() error        { return nil }
+func (txtWriter) ContentType() string { return "text/plain; charset=utf-8" }
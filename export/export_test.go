@@ -0,0 +1,73 @@
+package export_test
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/carlo-colombo/streamlog_go/export"
+	"github.com/carlo-colombo/streamlog_go/logentry"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Writer", func() {
+	timestamp := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	logs := []logentry.Log{
+		{
+			ID:        1,
+			Line:      "\x1b[31mfailed\x1b[0m: \"quoted\", <tag>",
+			Timestamp: timestamp,
+			Source:    "app",
+			Level:     "error",
+			Fields:    map[string]string{"host": "a"},
+		},
+		{
+			ID:            2,
+			Line:          "multi\nline",
+			Timestamp:     timestamp,
+			Repeat:        3,
+			LastTimestamp: timestamp.Add(time.Minute),
+		},
+	}
+
+	DescribeTable("writes the logs in the format",
+		func(format string, stripANSI bool, contentType, expected string) {
+			buffer := &bytes.Buffer{}
+			writer, err := export.NewWriter(format, buffer, stripANSI)
+			Expect(err).ToNot(HaveOccurred())
+
+			for _, l := range logs {
+				Expect(writer.Write(l)).To(Succeed())
+			}
+			Expect(writer.Flush()).To(Succeed())
+
+			Expect(writer.ContentType()).To(Equal(contentType))
+			Expect(buffer.String()).To(Equal(expected))
+		},
+		Entry("ndjson", "ndjson", false, "application/x-ndjson",
+			`{"id":1,"line":"\u001b[31mfailed\u001b[0m: \"quoted\", <tag>","timestamp":"2024-01-02T03:04:05Z","source":"app","level":"error","fields":{"host":"a"}}`+"\n"+
+				`{"id":2,"line":"multi\nline","timestamp":"2024-01-02T03:04:05Z","repeat":3,"last_timestamp":"2024-01-02T03:05:05Z"}`+"\n"),
+		Entry("csv, escaping quotes, commas and newlines", "csv", false, "text/csv",
+			"timestamp,source,level,line,fields,repeat,last_timestamp\n"+
+				"2024-01-02T03:04:05Z,app,error,\"\x1b[31mfailed\x1b[0m: \"\"quoted\"\", <tag>\",\"{\"\"host\"\":\"\"a\"\"}\",,\n"+
+				"2024-01-02T03:04:05Z,,,\"multi\nline\",,3,2024-01-02T03:05:05Z\n"),
+		Entry("txt without ANSI codes", "txt", true, "text/plain; charset=utf-8",
+			"2024-01-02T03:04:05Z [app] failed: \"quoted\", <tag>\n"+
+				"2024-01-02T03:04:05Z multi\nline\n"),
+	)
+
+	It("fails on unknown formats", func() {
+		_, err := export.NewWriter("xml", &bytes.Buffer{}, false)
+
+		Expect(err).To(MatchError(`unknown export format "xml", expected one of ndjson, csv, txt`))
+	})
+})
+
+var _ = DescribeTable("StripANSI",
+	func(s, expected string) {
+		Expect(export.StripANSI(s)).To(Equal(expected))
+	},
+	Entry("colors", "\x1b[1;31mred\x1b[0m", "red"),
+	Entry("cursor movements", "\x1b[2Kline\x1b[?25h", "line"),
+	Entry("plain text", "plain [text]", "plain [text]"),
+)
@@ -0,0 +1,202 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/carlo-colombo/streamlog_go/logentry"
+)
+
+// maxRecordSize is the longest NDJSON record ReadNDJSON accepts.
+const maxRecordSize = 1 << 20
+
+// Keys read from the NDJSON records of other tools, in order of preference.
+var (
+	lineKeys      = []string{"line", "message", "msg", "log"}
+	timestampKeys = []string{"timestamp", "@timestamp", "time", "ts"}
+	levelKeys     = []string{"level", "severity"}
+)
+
+// ReadNDJSON calls fn with the log of every record of r, an NDJSON export of
+// streamlog or of other tools. Timestamps are kept, records without one get
+// the current time. The keys of other tools that are not a line, timestamp,
+// level or source become fields.
+func ReadNDJSON(r io.Reader, fn func(l logentry.Log) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxRecordSize)
+
+	for n := 1; scanner.Scan(); n++ {
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		l, err := decodeRecord(data)
+		if err != nil {
+			return fmt.Errorf("record %d: %w", n, err)
+		}
+		if err := fn(l); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func decodeRecord(data []byte) (logentry.Log, error) {
+	var record map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&record); err != nil {
+		return logentry.Log{}, fmt.Errorf("invalid JSON object: %w", err)
+	}
+
+	var l logentry.Log
+	var ok bool
+	if l.Line, ok = takeString(record, lineKeys); !ok {
+		return logentry.Log{}, fmt.Errorf("no line, expected one of %s", strings.Join(lineKeys, ", "))
+	}
+
+	var err error
+	if l.Timestamp, err = takeTime(record, timestampKeys); err != nil {
+		return logentry.Log{}, err
+	}
+	if l.Timestamp.IsZero() {
+		l.Timestamp = time.Now()
+	}
+	l.Source, _ = takeString(record, []string{"source"})
+	if level, ok := takeString(record, levelKeys); ok {
+		l.Level = strings.ToLower(level)
+	}
+
+	if repeat, ok := record["repeat"].(json.Number); ok {
+		delete(record, "repeat")
+		if n, err := repeat.Int64(); err == nil && n > 1 {
+			l.Repeat = int(n)
+			if l.LastTimestamp, err = takeTime(record, []string{"last_timestamp"}); err != nil {
+				return logentry.Log{}, err
+			}
+		}
+	}
+	// the id of the exporting store means nothing to this one
+	delete(record, "id")
+
+	if fields, ok := record["fields"].(map[string]any); ok {
+		delete(record, "fields")
+		for key, value := range fields {
+			l.Fields = withField(l.Fields, key, value)
+		}
+	}
+	for key, value := range record {
+		l.Fields = withField(l.Fields, key, value)
+	}
+	return l, nil
+}
+
+// DecodeEntry reads an entry pushed by a client, a string taken as the line
+// or an object like the ones streamed by /logs. The line, timestamp (RFC 3339
+// or seconds since the epoch), source and level are read from the keys of
+// streamlog when they hold one, every other key becomes a field. Objects
+// without a line are stored as they are, their JSON being the line. Ids and
+// repeats are given by the store, not by clients.
+func DecodeEntry(data []byte) (logentry.Log, error) {
+	var line string
+	if err := json.Unmarshal(data, &line); err == nil {
+		return logentry.Log{Line: line}, nil
+	}
+
+	var record map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&record); err != nil {
+		return logentry.Log{}, fmt.Errorf("invalid JSON entry: %w", err)
+	}
+
+	var l logentry.Log
+	var ok bool
+	if l.Line, ok = takeString(record, []string{"line"}); !ok {
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, data); err != nil {
+			return logentry.Log{}, fmt.Errorf("invalid JSON entry: %w", err)
+		}
+		l.Line = compact.String()
+	}
+	// a timestamp that is not one is kept as a field
+	l.Timestamp, _ = takeTime(record, []string{"timestamp"})
+	l.Source, _ = takeString(record, []string{"source"})
+	l.Level, _ = takeString(record, []string{"level"})
+	for _, key := range []string{"id", "repeat", "last_timestamp"} {
+		delete(record, key)
+	}
+
+	if fields, ok := record["fields"].(map[string]any); ok {
+		delete(record, "fields")
+		for key, value := range fields {
+			l.Fields = withField(l.Fields, key, value)
+		}
+	}
+	for key, value := range record {
+		l.Fields = withField(l.Fields, key, value)
+	}
+	return l, nil
+}
+
+// takeString removes the first of keys holding a string from record.
+func takeString(record map[string]any, keys []string) (string, bool) {
+	for _, key := range keys {
+		if s, ok := record[key].(string); ok {
+			delete(record, key)
+			return s, true
+		}
+	}
+	return "", false
+}
+
+// takeTime removes the first of keys holding a time from record, either in
+// RFC 3339 or as seconds since the epoch.
+func takeTime(record map[string]any, keys []string) (time.Time, error) {
+	for _, key := range keys {
+		switch value := record[key].(type) {
+		case string:
+			t, err := time.Parse(time.RFC3339Nano, value)
+			if err != nil {
+				return time.Time{}, fmt.Errorf("invalid %s: %w", key, err)
+			}
+			delete(record, key)
+			return t, nil
+		case json.Number:
+			seconds, err := value.Float64()
+			if err != nil {
+				return time.Time{}, fmt.Errorf("invalid %s: %w", key, err)
+			}
+			delete(record, key)
+			return time.UnixMicro(int64(seconds * 1e6)).UTC(), nil
+		}
+	}
+	return time.Time{}, nil
+}
+
+func withField(fields map[string]string, key string, value any) map[string]string {
+	if value == nil {
+		return fields
+	}
+	if fields == nil {
+		fields = map[string]string{}
+	}
+	switch value := value.(type) {
+	case string:
+		fields[key] = value
+	case json.Number:
+		fields[key] = value.String()
+	case bool:
+		fields[key] = strconv.FormatBool(value)
+	default:
+		data, _ := json.Marshal(value)
+		fields[key] = string(data)
+	}
+	return fields
+}
@@ -0,0 +1,124 @@
+package export_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/carlo-colombo/streamlog_go/export"
+	"github.com/carlo-colombo/streamlog_go/logentry"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ReadNDJSON", func() {
+	read := func(data string) ([]logentry.Log, error) {
+		var logs []logentry.Log
+		err := export.ReadNDJSON(strings.NewReader(data), func(l logentry.Log) error {
+			logs = append(logs, l)
+			return nil
+		})
+		return logs, err
+	}
+
+	It("reads back the NDJSON exports", func() {
+		timestamp := time.Date(2024, 1, 2, 3, 4, 5, 123, time.UTC)
+		exported := []logentry.Log{
+			{ID: 1, Line: "first", Timestamp: timestamp, Source: "app", Level: "error", Fields: map[string]string{"host": "a"}},
+			{ID: 2, Line: "again", Timestamp: timestamp, Repeat: 3, LastTimestamp: timestamp.Add(time.Minute)},
+		}
+		buffer := &bytes.Buffer{}
+		writer, err := export.NewWriter("ndjson", buffer, false)
+		Expect(err).ToNot(HaveOccurred())
+		for _, l := range exported {
+			Expect(writer.Write(l)).To(Succeed())
+		}
+
+		logs, err := read(buffer.String())
+
+		Expect(err).ToNot(HaveOccurred())
+		exported[0].ID, exported[1].ID = 0, 0
+		Expect(logs).To(Equal(exported))
+	})
+
+	It("reads the records of other tools, keeping the other keys as fields", func() {
+		logs, err := read(`{"@timestamp":"2024-01-02T03:04:05Z","message":"hello","severity":"WARNING","user":{"id":7},"status":200,"ok":true,"none":null}
+{"ts":1704164645.5,"msg":"world"}
+`)
+
+		Expect(err).ToNot(HaveOccurred())
+		Expect(logs).To(HaveExactElements(
+			logentry.Log{
+				Line:      "hello",
+				Timestamp: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+				Level:     "warning",
+				Fields:    map[string]string{"user": `{"id":7}`, "status": "200", "ok": "true"},
+			},
+			logentry.Log{
+				Line:      "world",
+				Timestamp: time.Date(2024, 1, 2, 3, 4, 5, 5e8, time.UTC),
+			},
+		))
+	})
+
+	It("gives the current time to the records without one", func() {
+		logs, err := read(`{"line":"now"}`)
+
+		Expect(err).ToNot(HaveOccurred())
+		Expect(logs).To(HaveExactElements(HaveField("Timestamp", BeTemporally("~", time.Now(), time.Second))))
+	})
+
+	DescribeTable("fails on invalid records, after reading the previous ones",
+		func(data, message string) {
+			logs, err := read(`{"line":"valid"}` + "\n\n" + data)
+
+			Expect(err).To(MatchError(ContainSubstring(message)))
+			Expect(logs).To(HaveLen(1))
+		},
+		Entry("not JSON", "not json", "record 3: invalid JSON object"),
+		Entry("without a line", `{"level":"info"}`, "record 3: no line, expected one of line, message, msg, log"),
+		Entry("with an invalid timestamp", `{"line":"a","time":"yesterday"}`, "record 3: invalid time"),
+	)
+
+	It("stops at the first error of fn", func() {
+		err := export.ReadNDJSON(strings.NewReader("{\"line\":\"a\"}\n{\"line\":\"b\"}\n"), func(logentry.Log) error {
+			return errors.New("store closed")
+		})
+
+		Expect(err).To(MatchError("store closed"))
+	})
+})
+
+var _ = Describe("DecodeEntry", func() {
+	It("reads strings as the line", func() {
+		Expect(export.DecodeEntry([]byte(`"plain"`))).To(Equal(logentry.Log{Line: "plain"}))
+	})
+
+	It("reads the keys of streamlog, keeping the others as fields", func() {
+		l, err := export.DecodeEntry([]byte(`{"line":"a","timestamp":"2024-01-02T03:04:05Z","source":"ci","level":"warn","fields":{"host":"b"},"id":3,"repeat":2,"status":200}`))
+
+		Expect(err).ToNot(HaveOccurred())
+		Expect(l).To(Equal(logentry.Log{
+			Line:      "a",
+			Timestamp: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+			Source:    "ci",
+			Level:     "warn",
+			Fields:    map[string]string{"host": "b", "status": "200"},
+		}))
+	})
+
+	It("stores objects without a line as they are", func() {
+		l, err := export.DecodeEntry([]byte(`{ "level": 30, "msg": "x" }`))
+
+		Expect(err).ToNot(HaveOccurred())
+		Expect(l.Line).To(Equal(`{"level":30,"msg":"x"}`))
+		Expect(l.Fields).To(Equal(map[string]string{"level": "30", "msg": "x"}))
+	})
+
+	It("fails on invalid JSON", func() {
+		_, err := export.DecodeEntry([]byte(`{"line":`))
+
+		Expect(err).To(MatchError(ContainSubstring("invalid JSON entry")))
+	})
+})
@@ -3,6 +3,7 @@ module github.com/carlo-colombo/streamlog_go
 go 1.24.0
 
 require (
+	github.com/klauspost/compress v1.18.0
 	github.com/mattn/go-sqlite3 v1.14.24
 	github.com/onsi/ginkgo/v2 v2.22.2
 	github.com/onsi/gomega v1.36.2
@@ -1,13 +1,22 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
+	stdlog "log"
 	"math/rand"
+	"mime"
 	"net/http"
 	"strconv"
+	"time"
 
+	"github.com/carlo-colombo/streamlog_go/export"
+	"github.com/carlo-colombo/streamlog_go/logentry"
+	"github.com/carlo-colombo/streamlog_go/redact"
+	"github.com/carlo-colombo/streamlog_go/source"
 	"github.com/carlo-colombo/streamlog_go/sse"
 )
 
@@ -17,6 +26,13 @@ func ClientsHandler(store Store) func(http.ResponseWriter, *http.Request) {
 	}
 }
 
+// logUpdate is sent to the clients when a log they received is repeated.
+type logUpdate struct {
+	ID            int64     `json:"id"`
+	Repeat        int       `json:"repeat"`
+	LastTimestamp time.Time `json:"last_timestamp"`
+}
+
 func LogsHandler(store Store) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		flusher, _ := w.(http.Flusher)
@@ -29,6 +45,10 @@ func LogsHandler(store Store) func(http.ResponseWriter, *http.Request) {
 		for _, logItem := range store.List() {
 			_ = logItem.Encode(encoder)
 		}
+		// Clients assume a live stream, only tell late joiners that it ended
+		if status := store.Status(); status.Ended() {
+			_ = encoder.EncodeEvent("status", status)
+		}
 		flusher.Flush()
 
 		uid := strconv.Itoa(rand.Int())
@@ -39,7 +59,7 @@ func LogsHandler(store Store) func(http.ResponseWriter, *http.Request) {
 			case <-r.Context().Done():
 				store.Disconnect(uid)
 				break Response
-			case <-store.FilterChangeFor():
+			case <-store.FilterChangeFor(uid):
 				// Send reset event
 				fmt.Fprintf(w, "event: reset\ndata: reset\n\n")
 				flusher.Flush()
@@ -50,7 +70,19 @@ func LogsHandler(store Store) func(http.ResponseWriter, *http.Request) {
 				}
 				flusher.Flush()
 			case line := <-store.LineFor(uid):
-				_ = line.Encode(encoder)
+				if line.Update {
+					// the client already has the log, bump its counter
+					_ = encoder.EncodeEvent("update", logUpdate{
+						ID:            line.ID,
+						Repeat:        line.Repeat,
+						LastTimestamp: line.LastTimestamp,
+					})
+				} else {
+					_ = line.Encode(encoder)
+				}
+				flusher.Flush()
+			case status := <-store.StatusFor(uid):
+				_ = encoder.EncodeEvent("status", status)
 				flusher.Flush()
 			}
 		}
@@ -71,14 +103,265 @@ func FilterHandler(store Store) http.HandlerFunc {
 		}
 
 		var request struct {
-			Filter string `json:"filter"`
+			Filter string  `json:"filter"`
+			Source *string `json:"source"`
 		}
 		if err := json.Unmarshal(body, &request); err != nil {
 			http.Error(w, "Invalid JSON", http.StatusBadRequest)
 			return
 		}
 
+		// The source filter is only changed when present in the request
+		if request.Source != nil {
+			store.SetSourceFilter(*request.Source)
+		}
 		store.SetFilter(request.Filter)
 		w.WriteHeader(http.StatusOK)
 	}
 }
+
+// IngestHandler feeds the logs sent in the request body to the store. The
+// body is read according to its Content-Type: a JSON array of entries, one
+// entry per line (NDJSON), or plain text with one log per line. The source
+// query parameter tags the entries that do not carry their own. Bodies
+// compressed with gzip, zstd or bzip2 are decompressed.
+func IngestHandler(store Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		defaultSource := r.URL.Query().Get("source")
+		if defaultSource == "" {
+			defaultSource = "http"
+		}
+
+		body, err := source.Decompress(r.Body)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid body: %v", err), http.StatusBadRequest)
+			return
+		}
+		defer body.Close()
+
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+		var count int
+		ingest := func(l logentry.Log) {
+			if l.Timestamp.IsZero() {
+				l.Timestamp = time.Now()
+			}
+			if l.Source == "" {
+				l.Source = defaultSource
+			}
+			store.Ingest(l)
+			count++
+		}
+
+		switch mediaType {
+		case "application/json":
+			err = ingestJSON(body, ingest)
+		case "application/x-ndjson", "application/jsonl", "application/x-jsonlines":
+			err = ingestNDJSON(body, ingest)
+		default:
+			err = ingestText(body, ingest)
+		}
+
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid body after %d entries: %v", count, err), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]int{"ingested": count})
+	}
+}
+
+func ingestText(r io.Reader, ingest func(logentry.Log)) error {
+	scanner := source.NewLineScanner(r)
+	for scanner.Scan() {
+		ingest(logentry.Log{Line: scanner.Text()})
+	}
+	return scanner.Err()
+}
+
+func ingestNDJSON(r io.Reader, ingest func(logentry.Log)) error {
+	scanner := bufio.NewScanner(r)
+	// entries cannot be split like text lines
+	scanner.Buffer(nil, source.MaxLineLength)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		l, err := export.DecodeEntry(scanner.Bytes())
+		if err != nil {
+			return err
+		}
+		ingest(l)
+	}
+	return scanner.Err()
+}
+
+func ingestJSON(r io.Reader, ingest func(logentry.Log)) error {
+	var entries []json.RawMessage
+	if err := json.NewDecoder(r).Decode(&entries); err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		l, err := export.DecodeEntry(entry)
+		if err != nil {
+			return err
+		}
+		ingest(l)
+	}
+	return nil
+}
+
+func SourcesHandler(store Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sources := store.Sources()
+		if sources == nil {
+			sources = []string{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(sources); err != nil {
+			http.Error(w, "Error encoding sources", http.StatusInternalServerError)
+		}
+	}
+}
+
+// RedactionsHandler returns how many secrets each redaction rule replaced,
+// an empty object when redaction is disabled.
+func RedactionsHandler(redactor *redact.Redactor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		counts := map[string]int{}
+		if redactor != nil {
+			counts = redactor.Counts()
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(counts); err != nil {
+			http.Error(w, "Error encoding redactions", http.StatusInternalServerError)
+		}
+	}
+}
+
+// ImportHandler stores the logs of the NDJSON export in the body, which can
+// be compressed.
+func ImportHandler(store Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, err := source.Decompress(r.Body)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid body: %v", err), http.StatusBadRequest)
+			return
+		}
+		defer body.Close()
+
+		count, err := store.Import(body)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid body after %d entries: %v", count, err), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]int{"imported": count})
+	}
+}
+
+// SnapshotHandler writes a snapshot of the database in dir, responding with
+// its path.
+func SnapshotHandler(store Store, dir string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		path := SnapshotPath(dir, time.Now())
+		if err := store.Snapshot(path); err != nil {
+			stdlog.Printf("Failed to write snapshot: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]string{"path": path})
+	}
+}
+
+// ExportHandler streams the logs matching the current filter as a download,
+// in the format of the format parameter (ndjson by default). The optional
+// from and to parameters (RFC 3339) restrict the logs to a time range, and
+// strip_ansi removes the ANSI escape sequences from the lines.
+func ExportHandler(store Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		query := r.URL.Query()
+		var from, to time.Time
+		for name, t := range map[string]*time.Time{"from": &from, "to": &to} {
+			value := query.Get(name)
+			if value == "" {
+				continue
+			}
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Invalid %s, expected an RFC 3339 time: %v", name, err), http.StatusBadRequest)
+				return
+			}
+			*t = parsed
+		}
+		stripANSI, _ := strconv.ParseBool(query.Get("strip_ansi"))
+
+		format := query.Get("format")
+		if format == "" {
+			format = "ndjson"
+		}
+		writer, err := export.NewWriter(format, w, stripANSI)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", writer.ContentType())
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="logs.%s"`, format))
+
+		// the status is sent already, errors can only cut the download short
+		err = store.Export(from, to, writer.Write)
+		if err == nil {
+			err = writer.Flush()
+		}
+		if err != nil {
+			stdlog.Printf("Failed to export logs: %v", err)
+		}
+	}
+}
+
+// MetricsHandler returns the counts of the errors of the store.
+func MetricsHandler(store Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(store.Metrics()); err != nil {
+			http.Error(w, "Error encoding metrics", http.StatusInternalServerError)
+		}
+	}
+}
+
+func StatusHandler(store Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(store.Status()); err != nil {
+			http.Error(w, "Error encoding status", http.StatusInternalServerError)
+		}
+	}
+}
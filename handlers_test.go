@@ -2,8 +2,11 @@ package main_test
 
 import (
 	"bufio"
+	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -11,7 +14,9 @@ import (
 	"time"
 
 	main "github.com/carlo-colombo/streamlog_go"
+	"github.com/carlo-colombo/streamlog_go/export"
 	"github.com/carlo-colombo/streamlog_go/logentry"
+	"github.com/carlo-colombo/streamlog_go/redact"
 	"github.com/carlo-colombo/streamlog_go/test/utils"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -23,10 +28,32 @@ type mockStore struct {
 	logsCh         chan logentry.Log
 	disconnected   bool
 	filter         string
+	sourceFilter   string
+	sources        []string
 	filterChangeCh chan struct{}
+	status         main.IngestionStatus
+	statusCh       chan main.IngestionStatus
+	ingested       []logentry.Log
+	metrics        main.StoreMetrics
+	exportedFrom   time.Time
+	exportedTo     time.Time
+	snapshot       string
+	snapshotErr    error
 }
 
-func (m *mockStore) FilterChangeFor() chan struct{} {
+func (m *mockStore) Ingest(l logentry.Log) {
+	m.ingested = append(m.ingested, l)
+}
+
+func (m *mockStore) Status() main.IngestionStatus {
+	return m.status
+}
+
+func (m *mockStore) StatusFor(uid string) chan main.IngestionStatus {
+	return m.statusCh
+}
+
+func (m *mockStore) FilterChangeFor(uid string) chan struct{} {
 	return m.filterChangeCh
 }
 
@@ -46,6 +73,29 @@ func (m *mockStore) List() []logentry.Log {
 	return l
 }
 
+func (m *mockStore) Export(from, to time.Time, write func(logentry.Log) error) error {
+	m.exportedFrom, m.exportedTo = from, to
+	for _, l := range m.List() {
+		if err := write(l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *mockStore) Import(r io.Reader) (int, error) {
+	err := export.ReadNDJSON(r, func(l logentry.Log) error {
+		m.ingested = append(m.ingested, l)
+		return nil
+	})
+	return len(m.ingested), err
+}
+
+func (m *mockStore) Snapshot(path string) error {
+	m.snapshot = path
+	return m.snapshotErr
+}
+
 func (m *mockStore) Disconnect(uid string) {
 	m.disconnected = true
 }
@@ -62,6 +112,18 @@ func (m *mockStore) SetFilter(filter string) {
 	m.filter = filter
 }
 
+func (m *mockStore) SetSourceFilter(source string) {
+	m.sourceFilter = source
+}
+
+func (m *mockStore) Sources() []string {
+	return m.sources
+}
+
+func (m *mockStore) Metrics() main.StoreMetrics {
+	return m.metrics
+}
+
 var _ = Describe("Handlers", func() {
 	var req *http.Request
 	var rr *httptest.ResponseRecorder
@@ -174,6 +236,98 @@ var _ = Describe("Handlers", func() {
 			}).Should(Succeed())
 		})
 
+		It("sends the repeats of a log as update events", func() {
+			var store = &mockStore{logsCh: make(chan logentry.Log)}
+			handler := http.HandlerFunc(main.LogsHandler(store))
+
+			go func() {
+				handler.ServeHTTP(rr, req)
+			}()
+
+			last := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+			go func() {
+				store.logsCh <- logentry.Log{ID: 7, Line: "retry", Repeat: 3, LastTimestamp: last, Update: true}
+			}()
+
+			Eventually(func(g Gomega) {
+				scanner := bufio.NewScanner(rr.Body)
+				scanner.Split(utils.ScanEvent)
+
+				g.Expect(scanner.Scan()).To(BeTrue())
+				g.Expect(scanner.Text()).To(Equal(
+					"event: update\ndata: " + `{"id":7,"repeat":3,"last_timestamp":"2024-01-02T03:04:05Z"}`,
+				))
+			}).Should(Succeed())
+		})
+
+		It("sends the logs already repeated when stored as plain logs", func() {
+			var store = &mockStore{logsCh: make(chan logentry.Log)}
+			handler := http.HandlerFunc(main.LogsHandler(store))
+
+			go func() {
+				handler.ServeHTTP(rr, req)
+			}()
+
+			go func() {
+				store.logsCh <- logentry.Log{ID: 7, Line: "retry", Repeat: 3}
+			}()
+
+			Eventually(func(g Gomega) {
+				scanner := bufio.NewScanner(rr.Body)
+				scanner.Split(utils.ScanEvent)
+
+				g.Expect(scanner.Scan()).To(BeTrue())
+				g.Expect(scanner.Text()).To(HavePrefix("data: "))
+				g.Expect(scanner.Text()).To(ContainSubstring(`"line":"retry"`))
+			}).Should(Succeed())
+		})
+
+		It("sends ingestion status changes as a named event", func() {
+			var store = &mockStore{statusCh: make(chan main.IngestionStatus)}
+			handler := http.HandlerFunc(main.LogsHandler(store))
+
+			go func() {
+				handler.ServeHTTP(rr, req)
+			}()
+
+			go func() {
+				store.statusCh <- main.IngestionStatus{State: main.IngestionError, Error: "read failed"}
+			}()
+
+			Eventually(func(g Gomega) {
+				scanner := bufio.NewScanner(rr.Body)
+				scanner.Split(utils.ScanEvent)
+
+				g.Expect(scanner.Scan()).To(BeTrue())
+				g.Expect(scanner.Text()).To(HavePrefix("event: status\ndata: "))
+				g.Expect(scanner.Text()).To(ContainSubstring(`"state":"error","error":"read failed"`))
+			}).Should(Succeed())
+		})
+
+		It("tells clients connecting after the end of the input", func() {
+			var store = &mockStore{
+				logs:   []string{"log1"},
+				status: main.IngestionStatus{State: main.IngestionEOF},
+			}
+			handler := http.HandlerFunc(main.LogsHandler(store))
+
+			go func() {
+				handler.ServeHTTP(rr, req)
+			}()
+
+			// scanning consumes the body, read it once complete
+			Eventually(rr.Body.String).Should(ContainSubstring("event: status"))
+			scanner := bufio.NewScanner(rr.Body)
+			scanner.Split(utils.ScanEvent)
+
+			Expect(scanner.Scan()).To(BeTrue())
+			Expect(scanner.Text()).To(ContainSubstring("log1"))
+
+			Expect(scanner.Scan()).To(BeTrue())
+			Expect(scanner.Text()).To(HavePrefix("event: status\ndata: "))
+			Expect(scanner.Text()).To(ContainSubstring(`"state":"eof"`))
+		})
+
 		It("disconnects clients when the client closes the connection", func() {
 			var store = &mockStore{logs: []string{"log1", "log2"}}
 			clientsHandlerFunc := main.LogsHandler(store)
@@ -210,6 +364,26 @@ var _ = Describe("Handlers", func() {
 			Expect(store.filter).To(Equal("test"))
 		})
 
+		It("accepts an optional source filter", func() {
+			store := &mockStore{sourceFilter: "previous"}
+			handler := http.HandlerFunc(main.FilterHandler(store))
+
+			req, _ = http.NewRequest(http.MethodPost, "/filter", strings.NewReader(`{"filter":"test","source":"stdin"}`))
+			handler.ServeHTTP(rr, req)
+
+			Expect(rr).To(HaveHTTPStatus(http.StatusOK))
+			Expect(store.filter).To(Equal("test"))
+			Expect(store.sourceFilter).To(Equal("stdin"))
+
+			rr = httptest.NewRecorder()
+			req, _ = http.NewRequest(http.MethodPost, "/filter", strings.NewReader(`{"filter":"other"}`))
+			handler.ServeHTTP(rr, req)
+
+			Expect(rr).To(HaveHTTPStatus(http.StatusOK))
+			Expect(store.filter).To(Equal("other"))
+			Expect(store.sourceFilter).To(Equal("stdin"))
+		})
+
 		It("rejects non-POST requests", func() {
 			store := &mockStore{}
 			handler := http.HandlerFunc(main.FilterHandler(store))
@@ -232,4 +406,390 @@ var _ = Describe("Handlers", func() {
 			Expect(rr).To(HaveHTTPStatus(http.StatusBadRequest))
 		})
 	})
+
+	Describe("IngestHandler", func() {
+		var store *mockStore
+		var handler http.HandlerFunc
+
+		lines := func(logs []logentry.Log) []string {
+			var lines []string
+			for _, l := range logs {
+				lines = append(lines, l.Line)
+			}
+			return lines
+		}
+
+		BeforeEach(func() {
+			store = &mockStore{}
+			handler = main.IngestHandler(store)
+		})
+
+		It("ingests plain text one log per line", func() {
+			req, _ = http.NewRequest(http.MethodPost, "/ingest", strings.NewReader("first\nsecond\n"))
+			req.Header.Set("Content-Type", "text/plain")
+
+			handler.ServeHTTP(rr, req)
+
+			Expect(rr).To(SatisfyAll(
+				HaveHTTPStatus(http.StatusOK),
+				HaveHTTPBody(MatchJSON(`{"ingested":2}`)),
+			))
+			Expect(lines(store.ingested)).To(Equal([]string{"first", "second"}))
+			Expect(store.ingested).To(HaveEach(SatisfyAll(
+				HaveField("Source", "http"),
+				HaveField("Timestamp", Not(BeZero())),
+			)))
+		})
+
+		It("ingests plain text lines longer than 64 KiB", func() {
+			long := strings.Repeat("x", 100*1024)
+			req, _ = http.NewRequest(http.MethodPost, "/ingest", strings.NewReader(long+"\nnext\n"))
+			req.Header.Set("Content-Type", "text/plain")
+
+			handler.ServeHTTP(rr, req)
+
+			Expect(rr).To(HaveHTTPStatus(http.StatusOK))
+			Expect(lines(store.ingested)).To(Equal([]string{long, "next"}))
+		})
+
+		It("ingests NDJSON keeping the timestamp and source of the entries", func() {
+			body := `{"line":"first","timestamp":"2024-01-01T00:00:00Z","source":"ci"}
+
+{"level":"info","msg":"structured"}
+"a plain string"
+`
+			req, _ = http.NewRequest(http.MethodPost, "/ingest?source=job-42", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/x-ndjson")
+
+			handler.ServeHTTP(rr, req)
+
+			Expect(rr).To(HaveHTTPStatus(http.StatusOK))
+			Expect(store.ingested).To(HaveLen(3))
+			Expect(store.ingested[0]).To(SatisfyAll(
+				HaveField("Line", "first"),
+				HaveField("Source", "ci"),
+				HaveField("Timestamp", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)),
+			))
+			Expect(store.ingested[1]).To(SatisfyAll(
+				HaveField("Line", `{"level":"info","msg":"structured"}`),
+				HaveField("Source", "job-42"),
+			))
+			Expect(store.ingested[2].Line).To(Equal("a plain string"))
+		})
+
+		It("ignores the id and repeats sent by the client", func() {
+			body := `{"line":"hello","id":3,"repeat":7,"last_timestamp":"2024-01-01T00:00:00Z"}`
+			req, _ = http.NewRequest(http.MethodPost, "/ingest", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/x-ndjson")
+
+			handler.ServeHTTP(rr, req)
+
+			Expect(rr).To(HaveHTTPStatus(http.StatusOK))
+			Expect(store.ingested).To(HaveExactElements(SatisfyAll(
+				HaveField("Line", "hello"),
+				HaveField("ID", BeZero()),
+				HaveField("Repeat", BeZero()),
+				HaveField("LastTimestamp", BeZero()),
+			)))
+		})
+
+		It("ingests the records of other tools keeping their keys as fields", func() {
+			body := `{"level":30,"time":1700000000000,"pid":42,"msg":"from pino"}
+{"name":"app","hostname":"box","level":40,"time":"2024-01-01T00:00:00.000Z","msg":"from bunyan","v":0}
+{"line":"epoch","timestamp":1700000000}
+{"line":"string id","id":"abc"}
+{"line":"odd timestamp","timestamp":"yesterday"}
+`
+			req, _ = http.NewRequest(http.MethodPost, "/ingest", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/x-ndjson")
+
+			handler.ServeHTTP(rr, req)
+
+			Expect(rr).To(SatisfyAll(
+				HaveHTTPStatus(http.StatusOK),
+				HaveHTTPBody(MatchJSON(`{"ingested":5}`)),
+			))
+			Expect(store.ingested).To(HaveExactElements(
+				SatisfyAll(
+					HaveField("Line", `{"level":30,"time":1700000000000,"pid":42,"msg":"from pino"}`),
+					HaveField("Fields", map[string]string{"level": "30", "time": "1700000000000", "pid": "42", "msg": "from pino"}),
+				),
+				SatisfyAll(
+					HaveField("Line", ContainSubstring("from bunyan")),
+					HaveField("Fields", HaveKeyWithValue("level", "40")),
+					HaveField("Fields", HaveKeyWithValue("time", "2024-01-01T00:00:00.000Z")),
+				),
+				SatisfyAll(
+					HaveField("Line", "epoch"),
+					HaveField("Timestamp", time.Unix(1700000000, 0).UTC()),
+				),
+				SatisfyAll(
+					HaveField("Line", "string id"),
+					HaveField("ID", BeZero()),
+					HaveField("Fields", BeEmpty()),
+				),
+				SatisfyAll(
+					HaveField("Line", "odd timestamp"),
+					HaveField("Timestamp", BeTemporally("~", time.Now(), time.Second)),
+					HaveField("Fields", HaveKeyWithValue("timestamp", "yesterday")),
+				),
+			))
+		})
+
+		It("ingests a JSON array", func() {
+			req, _ = http.NewRequest(http.MethodPost, "/ingest", strings.NewReader(`[{"line":"first"},"second"]`))
+			req.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+			handler.ServeHTTP(rr, req)
+
+			Expect(rr).To(HaveHTTPStatus(http.StatusOK))
+			Expect(lines(store.ingested)).To(Equal([]string{"first", "second"}))
+		})
+
+		It("decompresses the body", func() {
+			var body bytes.Buffer
+			writer := gzip.NewWriter(&body)
+			_, _ = writer.Write([]byte(`{"line":"compressed"}` + "\n"))
+			Expect(writer.Close()).To(Succeed())
+
+			req, _ = http.NewRequest(http.MethodPost, "/ingest", &body)
+			req.Header.Set("Content-Type", "application/x-ndjson")
+
+			handler.ServeHTTP(rr, req)
+
+			Expect(rr).To(HaveHTTPStatus(http.StatusOK))
+			Expect(lines(store.ingested)).To(Equal([]string{"compressed"}))
+		})
+
+		It("rejects invalid JSON reporting how many entries were ingested", func() {
+			req, _ = http.NewRequest(http.MethodPost, "/ingest", strings.NewReader("{\"line\":\"first\"}\nnot json\n"))
+			req.Header.Set("Content-Type", "application/x-ndjson")
+
+			handler.ServeHTTP(rr, req)
+
+			Expect(rr).To(SatisfyAll(
+				HaveHTTPStatus(http.StatusBadRequest),
+				HaveHTTPBody(ContainSubstring("Invalid body after 1 entries")),
+			))
+			Expect(lines(store.ingested)).To(Equal([]string{"first"}))
+		})
+
+		It("rejects non-POST requests", func() {
+			req, _ = http.NewRequest(http.MethodGet, "/ingest", nil)
+
+			handler.ServeHTTP(rr, req)
+
+			Expect(rr).To(HaveHTTPStatus(http.StatusMethodNotAllowed))
+		})
+	})
+
+	Describe("SourcesHandler", func() {
+		It("returns the sources as a JSON array", func() {
+			store := &mockStore{sources: []string{"app.log", "stdin"}}
+			handler := http.HandlerFunc(main.SourcesHandler(store))
+
+			handler.ServeHTTP(rr, req)
+
+			Expect(rr).To(SatisfyAll(
+				HaveHTTPStatus(http.StatusOK),
+				HaveHTTPHeaderWithValue("Content-Type", "application/json"),
+				HaveHTTPBody(MatchJSON(`["app.log","stdin"]`)),
+			))
+		})
+
+		It("returns an empty array when there are no sources", func() {
+			handler := http.HandlerFunc(main.SourcesHandler(&mockStore{}))
+
+			handler.ServeHTTP(rr, req)
+
+			Expect(rr).To(HaveHTTPBody(MatchJSON(`[]`)))
+		})
+	})
+
+	Describe("RedactionsHandler", func() {
+		It("returns the count of redactions per rule", func() {
+			redactor := redact.New(redact.Builtin()...)
+			redactor.Redact("Bearer token")
+			handler := http.HandlerFunc(main.RedactionsHandler(redactor))
+
+			handler.ServeHTTP(rr, req)
+
+			Expect(rr).To(SatisfyAll(
+				HaveHTTPStatus(http.StatusOK),
+				HaveHTTPBody(MatchJSON(`{"bearer":1,"url-password":0,"credit-card":0}`)),
+			))
+		})
+
+		It("returns an empty object when redaction is disabled", func() {
+			handler := http.HandlerFunc(main.RedactionsHandler(nil))
+
+			handler.ServeHTTP(rr, req)
+
+			Expect(rr).To(HaveHTTPBody(MatchJSON(`{}`)))
+		})
+	})
+
+	Describe("StatusHandler", func() {
+		It("returns the ingestion status as JSON", func() {
+			store := &mockStore{status: main.IngestionStatus{State: main.IngestionEOF}}
+			handler := http.HandlerFunc(main.StatusHandler(store))
+
+			handler.ServeHTTP(rr, req)
+
+			Expect(rr).To(SatisfyAll(
+				HaveHTTPStatus(http.StatusOK),
+				HaveHTTPHeaderWithValue("Content-Type", "application/json"),
+				HaveHTTPBody(ContainSubstring(`"state":"eof"`)),
+			))
+		})
+	})
+
+	Describe("SnapshotHandler", func() {
+		It("writes a snapshot in the directory and responds with its path", func() {
+			store := &mockStore{}
+			handler := http.HandlerFunc(main.SnapshotHandler(store, "/backups"))
+			req = httptest.NewRequest(http.MethodPost, "/api/snapshot", nil)
+
+			handler.ServeHTTP(rr, req)
+
+			Expect(store.snapshot).To(MatchRegexp(`^/backups/streamlog-\d{8}T\d{6}\.\d{3}Z\.db$`))
+			Expect(rr).To(SatisfyAll(
+				HaveHTTPStatus(http.StatusOK),
+				HaveHTTPBody(MatchJSON(`{"path":"`+store.snapshot+`"}`)),
+			))
+		})
+
+		It("responds with the error when the snapshot fails", func() {
+			store := &mockStore{snapshotErr: errors.New("disk full")}
+			handler := http.HandlerFunc(main.SnapshotHandler(store, "/backups"))
+			req = httptest.NewRequest(http.MethodPost, "/api/snapshot", nil)
+
+			handler.ServeHTTP(rr, req)
+
+			Expect(rr).To(SatisfyAll(
+				HaveHTTPStatus(http.StatusInternalServerError),
+				HaveHTTPBody(ContainSubstring("disk full")),
+			))
+		})
+
+		It("only accepts POST", func() {
+			store := &mockStore{}
+			handler := http.HandlerFunc(main.SnapshotHandler(store, "/backups"))
+
+			handler.ServeHTTP(rr, req)
+
+			Expect(rr).To(HaveHTTPStatus(http.StatusMethodNotAllowed))
+			Expect(store.snapshot).To(BeEmpty())
+		})
+	})
+
+	Describe("ImportHandler", func() {
+		It("imports the NDJSON export in the body", func() {
+			store := &mockStore{}
+			handler := http.HandlerFunc(main.ImportHandler(store))
+			req = httptest.NewRequest(http.MethodPost, "/api/import", strings.NewReader(
+				`{"line":"old","timestamp":"2024-01-02T03:04:05Z","source":"app","fields":{"host":"a"}}`+"\n",
+			))
+
+			handler.ServeHTTP(rr, req)
+
+			Expect(rr).To(SatisfyAll(
+				HaveHTTPStatus(http.StatusOK),
+				HaveHTTPBody(MatchJSON(`{"imported":1}`)),
+			))
+			Expect(store.ingested).To(HaveExactElements(logentry.Log{
+				Line:      "old",
+				Timestamp: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+				Source:    "app",
+				Fields:    map[string]string{"host": "a"},
+			}))
+		})
+
+		It("reports how many logs were imported before an invalid record", func() {
+			handler := http.HandlerFunc(main.ImportHandler(&mockStore{}))
+			req = httptest.NewRequest(http.MethodPost, "/api/import", strings.NewReader("{\"line\":\"a\"}\nnot json\n"))
+
+			handler.ServeHTTP(rr, req)
+
+			Expect(rr).To(SatisfyAll(
+				HaveHTTPStatus(http.StatusBadRequest),
+				HaveHTTPBody(ContainSubstring("Invalid body after 1 entries: record 2: invalid JSON object")),
+			))
+		})
+
+		It("only accepts POST", func() {
+			handler := http.HandlerFunc(main.ImportHandler(&mockStore{}))
+
+			handler.ServeHTTP(rr, req)
+
+			Expect(rr).To(HaveHTTPStatus(http.StatusMethodNotAllowed))
+		})
+	})
+
+	Describe("ExportHandler", func() {
+		It("downloads the logs as NDJSON by default", func() {
+			store := &mockStore{logs: []string{"log1", "log2"}}
+			handler := http.HandlerFunc(main.ExportHandler(store))
+
+			handler.ServeHTTP(rr, req)
+
+			Expect(rr).To(SatisfyAll(
+				HaveHTTPStatus(http.StatusOK),
+				HaveHTTPHeaderWithValue("Content-Type", "application/x-ndjson"),
+				HaveHTTPHeaderWithValue("Content-Disposition", `attachment; filename="logs.ndjson"`),
+			))
+			Expect(strings.Split(strings.TrimSpace(rr.Body.String()), "\n")).To(HaveExactElements(
+				ContainSubstring(`"line":"log1"`),
+				ContainSubstring(`"line":"log2"`),
+			))
+		})
+
+		It("exports the time range in the format, stripping the ANSI codes", func() {
+			store := &mockStore{logs: []string{"\x1b[31mred\x1b[0m"}}
+			handler := http.HandlerFunc(main.ExportHandler(store))
+			req = httptest.NewRequest(http.MethodGet,
+				"/api/export?format=csv&strip_ansi=true&from=2024-01-02T00:00:00Z&to=2024-01-03T00:00:00%2B01:00", nil)
+
+			handler.ServeHTTP(rr, req)
+
+			Expect(rr).To(SatisfyAll(
+				HaveHTTPStatus(http.StatusOK),
+				HaveHTTPHeaderWithValue("Content-Type", "text/csv"),
+				HaveHTTPBody(MatchRegexp(`^timestamp,.*\n[^,]*,,,red,,,\n$`)),
+			))
+			Expect(store.exportedFrom).To(BeTemporally("==", time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)))
+			Expect(store.exportedTo).To(BeTemporally("==", time.Date(2024, 1, 2, 23, 0, 0, 0, time.UTC)))
+		})
+
+		DescribeTable("rejects invalid parameters",
+			func(query, message string) {
+				handler := http.HandlerFunc(main.ExportHandler(&mockStore{}))
+				req = httptest.NewRequest(http.MethodGet, "/api/export?"+query, nil)
+
+				handler.ServeHTTP(rr, req)
+
+				Expect(rr).To(SatisfyAll(
+					HaveHTTPStatus(http.StatusBadRequest),
+					HaveHTTPBody(ContainSubstring(message)),
+				))
+			},
+			Entry("format", "format=xml", `unknown export format "xml"`),
+			Entry("time", "from=yesterday", "Invalid from, expected an RFC 3339 time"),
+		)
+	})
+
+	Describe("MetricsHandler", func() {
+		It("returns the error counts of the store as JSON", func() {
+			store := &mockStore{metrics: main.StoreMetrics{Retries: 3, TransientErrors: 1, DroppedLogs: 20}}
+			handler := http.HandlerFunc(main.MetricsHandler(store))
+
+			handler.ServeHTTP(rr, req)
+
+			Expect(rr).To(SatisfyAll(
+				HaveHTTPStatus(http.StatusOK),
+				HaveHTTPHeaderWithValue("Content-Type", "application/json"),
+				HaveHTTPBody(MatchJSON(`{"retries":3,"transient_errors":1,"permanent_errors":0,"dropped_logs":20}`)),
+			))
+		})
+	})
 })
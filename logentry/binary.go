@@ -0,0 +1,99 @@
+package logentry
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// BinaryPolicy is how the invalid UTF-8 bytes and control characters of a
+// log are made safe to store and display.
+type BinaryPolicy string
+
+const (
+	// BinaryReplace replaces them with U+FFFD.
+	BinaryReplace BinaryPolicy = "replace"
+	// BinaryEscape writes them as \xNN.
+	BinaryEscape BinaryPolicy = "escape"
+	// BinaryBase64 encodes the whole line in base64, flagged by an
+	// "encoding" field. Fields are escaped.
+	BinaryBase64 BinaryPolicy = "base64"
+)
+
+var BinaryPolicies = []BinaryPolicy{BinaryReplace, BinaryEscape, BinaryBase64}
+
+func ParseBinaryPolicy(s string) (BinaryPolicy, error) {
+	for _, policy := range BinaryPolicies {
+		if string(policy) == s {
+			return policy, nil
+		}
+	}
+	return "", fmt.Errorf("unknown binary policy %q, expected one of replace, escape, base64", s)
+}
+
+// Sanitize applies the policy to the line and fields of l. Tabs and escape
+// characters are kept, so that ANSI colors still work.
+func (p BinaryPolicy) Sanitize(l Log) Log {
+	if p == BinaryBase64 {
+		if !isPrintable(l.Line) {
+			l.Fields = withField(l.Fields, "encoding", "base64")
+			l.Line = base64.StdEncoding.EncodeToString([]byte(l.Line))
+		}
+		p = BinaryEscape
+	} else {
+		l.Line = p.sanitize(l.Line)
+	}
+
+	for key, value := range l.Fields {
+		if !isPrintable(value) {
+			l.Fields = withField(l.Fields, key, p.sanitize(value))
+		}
+	}
+	return l
+}
+
+func (p BinaryPolicy) sanitize(s string) string {
+	if isPrintable(s) {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if (r == utf8.RuneError && size == 1) || isControl(r) {
+			if p == BinaryEscape {
+				for _, c := range []byte(s[i : i+size]) {
+					fmt.Fprintf(&b, `\x%02x`, c)
+				}
+			} else {
+				b.WriteRune(utf8.RuneError)
+			}
+		} else {
+			b.WriteString(s[i : i+size])
+		}
+		i += size
+	}
+	return b.String()
+}
+
+func isPrintable(s string) bool {
+	return utf8.ValidString(s) && strings.IndexFunc(s, isControl) < 0
+}
+
+// isControl reports the C0 control characters and DEL, except for tabs and
+// escape characters.
+func isControl(r rune) bool {
+	return (r < 0x20 && r != '\t' && r != 0x1b) || r == 0x7f
+}
+
+// withField sets a field on a copy of fields, which may be shared with other
+// copies of the log.
+func withField(fields map[string]string, key, value string) map[string]string {
+	copied := make(map[string]string, len(fields)+1)
+	for k, v := range fields {
+		copied[k] = v
+	}
+	copied[key] = value
+	return copied
+}
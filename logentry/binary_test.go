@@ -0,0 +1,54 @@
+package logentry_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/carlo-colombo/streamlog_go/logentry"
+)
+
+var _ = Describe("BinaryPolicy", func() {
+	const garbage = "ok\x00\xff\xfe\x1b[31mred\x1b[0m\tcafé\x07"
+
+	DescribeTable("sanitizes the line",
+		func(policy logentry.BinaryPolicy, expected string) {
+			l := policy.Sanitize(logentry.Log{Line: garbage})
+
+			Expect(l.Line).To(Equal(expected))
+		},
+		Entry("replace", logentry.BinaryReplace, "ok���\x1b[31mred\x1b[0m\tcafé�"),
+		Entry("escape", logentry.BinaryEscape, `ok\x00\xff\xfe`+"\x1b[31mred\x1b[0m\tcafé"+`\x07`),
+		Entry("base64", logentry.BinaryBase64, "b2sA//4bWzMxbXJlZBtbMG0JY2Fmw6kH"),
+	)
+
+	It("flags base64 encoded lines", func() {
+		l := logentry.BinaryBase64.Sanitize(logentry.Log{Line: "\x00", Fields: map[string]string{"stream": "stdout"}})
+
+		Expect(l.Fields).To(Equal(map[string]string{"stream": "stdout", "encoding": "base64"}))
+	})
+
+	It("keeps printable lines as they are", func() {
+		for _, policy := range logentry.BinaryPolicies {
+			l := policy.Sanitize(logentry.Log{Line: "plain \x1b[1mbold\x1b[0m ünïcode"})
+
+			Expect(l.Line).To(Equal("plain \x1b[1mbold\x1b[0m ünïcode"))
+			Expect(l.Fields).To(BeNil())
+		}
+	})
+
+	It("sanitizes the fields without changing the original ones", func() {
+		fields := map[string]string{"binary": "a\x00b"}
+
+		l := logentry.BinaryBase64.Sanitize(logentry.Log{Line: "line", Fields: fields})
+
+		Expect(l.Fields).To(Equal(map[string]string{"binary": `a\x00b`}))
+		Expect(fields).To(Equal(map[string]string{"binary": "a\x00b"}))
+	})
+
+	It("parses the policies", func() {
+		Expect(logentry.ParseBinaryPolicy("escape")).To(Equal(logentry.BinaryEscape))
+
+		_, err := logentry.ParseBinaryPolicy("drop")
+		Expect(err).To(MatchError(`unknown binary policy "drop", expected one of replace, escape, base64`))
+	})
+})
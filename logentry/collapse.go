@@ -0,0 +1,48 @@
+package logentry
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// CollapseMode is how consecutive repeats of a log are told apart from new
+// logs.
+type CollapseMode string
+
+const (
+	// CollapseOff stores every log.
+	CollapseOff CollapseMode = "off"
+	// CollapseExact collapses the logs with the same line.
+	CollapseExact CollapseMode = "exact"
+	// CollapseMasked collapses the logs whose lines differ only in numbers,
+	// UUIDs and hexadecimal values.
+	CollapseMasked CollapseMode = "masked"
+)
+
+var CollapseModes = []CollapseMode{CollapseOff, CollapseExact, CollapseMasked}
+
+func ParseCollapseMode(s string) (CollapseMode, error) {
+	for _, mode := range CollapseModes {
+		if string(mode) == s {
+			return mode, nil
+		}
+	}
+	return "", fmt.Errorf("unknown collapse mode %q, expected one of off, exact, masked", s)
+}
+
+var (
+	uuidPattern   = regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`)
+	hexPattern    = regexp.MustCompile(`0[xX][0-9a-fA-F]+`)
+	numberPattern = regexp.MustCompile(`\d+`)
+)
+
+// Key returns what the lines of two logs have in common when one repeats the
+// other.
+func (m CollapseMode) Key(line string) string {
+	if m != CollapseMasked {
+		return line
+	}
+	line = uuidPattern.ReplaceAllString(line, "<uuid>")
+	line = hexPattern.ReplaceAllString(line, "<hex>")
+	return numberPattern.ReplaceAllString(line, "<n>")
+}
@@ -0,0 +1,30 @@
+package logentry_test
+
+import (
+	"github.com/carlo-colombo/streamlog_go/logentry"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CollapseMode", func() {
+	DescribeTable("tells repeats apart",
+		func(mode logentry.CollapseMode, a, b string, repeat bool) {
+			Expect(mode.Key(a) == mode.Key(b)).To(Equal(repeat))
+		},
+		Entry("exact, same line", logentry.CollapseExact, "retrying", "retrying", true),
+		Entry("exact, different numbers", logentry.CollapseExact, "retry 1", "retry 2", false),
+		Entry("masked, different numbers", logentry.CollapseMasked,
+			"retry 1 in 200ms", "retry 2 in 400ms", true),
+		Entry("masked, different UUIDs and hex values", logentry.CollapseMasked,
+			"request 123e4567-e89b-12d3-a456-426614174000 at 0xdeadbeef",
+			"request 00000000-0000-0000-0000-00000000abcd at 0xC0FFEE", true),
+		Entry("masked, different words", logentry.CollapseMasked, "retry 1", "failed 1", false),
+	)
+
+	It("parses the modes", func() {
+		Expect(logentry.ParseCollapseMode("masked")).To(Equal(logentry.CollapseMasked))
+
+		_, err := logentry.ParseCollapseMode("fuzzy")
+		Expect(err).To(MatchError(`unknown collapse mode "fuzzy", expected one of off, exact, masked`))
+	})
+})
@@ -0,0 +1,51 @@
+package logentry
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// CRIDecoder reads the records written by CRI runtimes (containerd, CRI-O)
+// in /var/log/containers:
+//
+//	2024-01-01T00:00:00.000000000Z stdout F message
+//
+// The tag is P for the parts of a line split over several records, and F for
+// its last part. Lines that are not CRI records are kept as they are.
+type CRIDecoder struct {
+	partials partials
+}
+
+var criRecord = regexp.MustCompile(`^(\S+) (stdout|stderr) ([^ ]+) ?(.*)$`)
+
+func NewCRIDecoder() *CRIDecoder {
+	return &CRIDecoder{partials: partials{}}
+}
+
+func isCRIRecord(line string) bool {
+	match := criRecord.FindStringSubmatch(line)
+	if match == nil {
+		return false
+	}
+	_, err := time.Parse(time.RFC3339Nano, match[1])
+	return err == nil
+}
+
+func (d *CRIDecoder) Decode(line string) (Log, bool) {
+	match := criRecord.FindStringSubmatch(line)
+	if match == nil {
+		return NewLog(line), true
+	}
+	timestamp, err := time.Parse(time.RFC3339Nano, match[1])
+	if err != nil {
+		return NewLog(line), true
+	}
+	stream, tags, message := match[2], strings.Split(match[3], ":"), match[4]
+
+	l := NewLog(message)
+	l.Timestamp = timestamp
+	l.Fields = map[string]string{"stream": stream}
+
+	return d.partials.add(l, stream, tags[0] == "P")
+}
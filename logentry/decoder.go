@@ -0,0 +1,98 @@
+package logentry
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+)
+
+// Decoder turns the lines read from an input into log entries. Decoders
+// keep the state needed to reassemble lines split over several records, so
+// every input needs its own.
+type Decoder interface {
+	// Decode returns the entry for line. ok is false when line is only a
+	// part of an entry, which is returned once its last part is decoded.
+	Decode(line string) (l Log, ok bool)
+}
+
+// ReadDecoder is a Decoder of a format that is not made of lines only, which
+// reads the entries from the input itself.
+type ReadDecoder interface {
+	Decoder
+	// Read returns the next entry read from r, io.EOF once r ended.
+	Read(r *bufio.Reader) (Log, error)
+}
+
+// Formats lists the names accepted by NewDecoderFactory.
+var Formats = []string{"raw", "docker", "cri", "journal", "journal-json", "auto"}
+
+// NewDecoderFactory returns a constructor for the decoders of format.
+func NewDecoderFactory(format string) (func() Decoder, error) {
+	switch format {
+	case "raw", "":
+		return func() Decoder { return RawDecoder{} }, nil
+	case "docker":
+		return func() Decoder { return NewDockerDecoder() }, nil
+	case "cri":
+		return func() Decoder { return NewCRIDecoder() }, nil
+	case "journal":
+		return func() Decoder { return NewJournalExportDecoder() }, nil
+	case "journal-json":
+		return func() Decoder { return JournalJSONDecoder{} }, nil
+	case "auto":
+		return func() Decoder { return NewAutoDecoder() }, nil
+	}
+	return nil, fmt.Errorf("unknown format %q, expected one of %s", format, strings.Join(Formats, ", "))
+}
+
+// RawDecoder keeps every line as it is.
+type RawDecoder struct{}
+
+func (RawDecoder) Decode(line string) (Log, bool) {
+	return NewLog(line), true
+}
+
+// AutoDecoder recognizes docker, CRI and journald JSON records line by line,
+// other lines are kept as they are.
+type AutoDecoder struct {
+	docker *DockerDecoder
+	cri    *CRIDecoder
+}
+
+func NewAutoDecoder() *AutoDecoder {
+	return &AutoDecoder{
+		docker: NewDockerDecoder(),
+		cri:    NewCRIDecoder(),
+	}
+}
+
+func (d *AutoDecoder) Decode(line string) (Log, bool) {
+	switch {
+	case isDockerRecord(line):
+		return d.docker.Decode(line)
+	case isCRIRecord(line):
+		return d.cri.Decode(line)
+	case isJournalJSONRecord(line):
+		return JournalJSONDecoder{}.Decode(line)
+	}
+	return NewLog(line), true
+}
+
+// partials reassembles the parts of the lines of each stream.
+type partials map[string]*Log
+
+// add appends line to the pending entry of the stream, returning the whole
+// entry when line is its last part.
+func (p partials) add(l Log, stream string, partial bool) (Log, bool) {
+	if pending, ok := p[stream]; ok {
+		pending.Line += l.Line
+		l = *pending
+	}
+
+	if partial {
+		p[stream] = &l
+		return Log{}, false
+	}
+	delete(p, stream)
+	return l, true
+}
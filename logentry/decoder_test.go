@@ -0,0 +1,126 @@
+package logentry_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/carlo-colombo/streamlog_go/logentry"
+)
+
+var _ = Describe("Decoder", func() {
+	decodeAll := func(decoder logentry.Decoder, lines ...string) []logentry.Log {
+		var logs []logentry.Log
+		for _, line := range lines {
+			if l, ok := decoder.Decode(line); ok {
+				logs = append(logs, l)
+			}
+		}
+		return logs
+	}
+
+	Describe("NewDecoderFactory", func() {
+		It("returns a new decoder for every call", func() {
+			factory, err := logentry.NewDecoderFactory("cri")
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(factory()).ToNot(BeIdenticalTo(factory()))
+		})
+
+		It("fails on unknown formats", func() {
+			_, err := logentry.NewDecoderFactory("xml")
+			Expect(err).To(MatchError(`unknown format "xml", expected one of raw, docker, cri, journal, journal-json, auto`))
+		})
+	})
+
+	Describe("Docker", func() {
+		It("unwraps the records", func() {
+			logs := decodeAll(logentry.NewDockerDecoder(),
+				`{"log":"hello\n","stream":"stderr","time":"2024-01-01T10:00:00.5Z","attrs":{"tag":"web"}}`)
+
+			Expect(logs).To(HaveExactElements(SatisfyAll(
+				HaveField("Line", "hello"),
+				HaveField("Timestamp", time.Date(2024, 1, 1, 10, 0, 0, 500_000_000, time.UTC)),
+				HaveField("Fields", Equal(map[string]string{"stream": "stderr", "tag": "web"})),
+			)))
+		})
+
+		It("reassembles partial lines of each stream", func() {
+			logs := decodeAll(logentry.NewDockerDecoder(),
+				`{"log":"a long ","stream":"stdout","time":"2024-01-01T10:00:00Z"}`,
+				`{"log":"error\n","stream":"stderr","time":"2024-01-01T10:00:01Z"}`,
+				`{"log":"line\n","stream":"stdout","time":"2024-01-01T10:00:02Z"}`,
+			)
+
+			Expect(logs).To(HaveExactElements(
+				HaveField("Line", "error"),
+				SatisfyAll(
+					HaveField("Line", "a long line"),
+					HaveField("Timestamp", time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)),
+				),
+			))
+		})
+
+		It("keeps other lines as they are", func() {
+			Expect(decodeAll(logentry.NewDockerDecoder(), `{"msg":"not docker"}`, "plain")).To(HaveExactElements(
+				HaveField("Line", `{"msg":"not docker"}`),
+				HaveField("Line", "plain"),
+			))
+		})
+	})
+
+	Describe("CRI", func() {
+		It("unwraps the records", func() {
+			logs := decodeAll(logentry.NewCRIDecoder(), "2024-01-01T10:00:00.123456789Z stdout F hello world")
+
+			Expect(logs).To(HaveExactElements(SatisfyAll(
+				HaveField("Line", "hello world"),
+				HaveField("Timestamp", time.Date(2024, 1, 1, 10, 0, 0, 123456789, time.UTC)),
+				HaveField("Fields", Equal(map[string]string{"stream": "stdout"})),
+			)))
+		})
+
+		It("reassembles partial lines of each stream", func() {
+			logs := decodeAll(logentry.NewCRIDecoder(),
+				"2024-01-01T10:00:00Z stdout P a long ",
+				"2024-01-01T10:00:01Z stderr F error",
+				"2024-01-01T10:00:02Z stdout P multi ",
+				"2024-01-01T10:00:03Z stdout F line",
+			)
+
+			Expect(logs).To(HaveExactElements(
+				HaveField("Line", "error"),
+				HaveField("Line", "a long multi line"),
+			))
+		})
+
+		It("accepts empty messages", func() {
+			Expect(decodeAll(logentry.NewCRIDecoder(), "2024-01-01T10:00:00Z stdout F")).
+				To(HaveExactElements(HaveField("Line", "")))
+		})
+
+		It("keeps other lines as they are", func() {
+			Expect(decodeAll(logentry.NewCRIDecoder(), "yesterday stdout F message")).
+				To(HaveExactElements(HaveField("Line", "yesterday stdout F message")))
+		})
+	})
+
+	Describe("Auto", func() {
+		It("recognizes the format of every line", func() {
+			logs := decodeAll(logentry.NewAutoDecoder(),
+				`{"log":"from docker\n","stream":"stdout","time":"2024-01-01T10:00:00Z"}`,
+				"2024-01-01T10:00:00Z stderr F from cri",
+				`{"__CURSOR":"s=1","MESSAGE":"from journald","PRIORITY":"2"}`,
+				"plain line",
+			)
+
+			Expect(logs).To(HaveExactElements(
+				SatisfyAll(HaveField("Line", "from docker"), HaveField("Fields", HaveKeyWithValue("stream", "stdout"))),
+				SatisfyAll(HaveField("Line", "from cri"), HaveField("Fields", HaveKeyWithValue("stream", "stderr"))),
+				SatisfyAll(HaveField("Line", "from journald"), HaveField("Level", "critical")),
+				SatisfyAll(HaveField("Line", "plain line"), HaveField("Fields", BeNil())),
+			))
+		})
+	})
+})
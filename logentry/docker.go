@@ -0,0 +1,53 @@
+package logentry
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// DockerDecoder reads the records of the docker json-file logging driver:
+//
+//	{"log":"message\n","stream":"stderr","time":"2024-01-01T00:00:00.000000000Z"}
+//
+// Long lines are split over records whose log does not end with a newline.
+// Lines that are not docker records are kept as they are.
+type DockerDecoder struct {
+	partials partials
+}
+
+type dockerRecord struct {
+	Log    *string           `json:"log"`
+	Stream string            `json:"stream"`
+	Time   time.Time         `json:"time"`
+	Attrs  map[string]string `json:"attrs"`
+}
+
+func NewDockerDecoder() *DockerDecoder {
+	return &DockerDecoder{partials: partials{}}
+}
+
+func isDockerRecord(line string) bool {
+	return strings.HasPrefix(line, `{"log":`)
+}
+
+func (d *DockerDecoder) Decode(line string) (Log, bool) {
+	var record dockerRecord
+	if err := json.Unmarshal([]byte(line), &record); err != nil || record.Log == nil {
+		return NewLog(line), true
+	}
+
+	l := NewLog(strings.TrimSuffix(*record.Log, "\n"))
+	if !record.Time.IsZero() {
+		l.Timestamp = record.Time
+	}
+	l.Fields = map[string]string{}
+	for k, v := range record.Attrs {
+		l.Fields[k] = v
+	}
+	if record.Stream != "" {
+		l.Fields["stream"] = record.Stream
+	}
+
+	return d.partials.add(l, record.Stream, !strings.HasSuffix(*record.Log, "\n"))
+}
@@ -0,0 +1,196 @@
+package logentry
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// JournalExportDecoder reads the export format of journald, as written by
+// `journalctl -o export`: entries are KEY=VALUE lines ended by an empty
+// line. Values that are not printable are written as the name of the field
+// on its own line, followed by their length as a little-endian uint64, the
+// value and a newline.
+type JournalExportDecoder struct {
+	fields map[string]string
+}
+
+func NewJournalExportDecoder() *JournalExportDecoder {
+	return &JournalExportDecoder{fields: map[string]string{}}
+}
+
+// Decode reads the entries line by line, which cannot tell where binary
+// values end: the lines of their fields are skipped, Read reads them.
+func (d *JournalExportDecoder) Decode(line string) (Log, bool) {
+	if line == "" {
+		return d.entry()
+	}
+	if key, value, ok := strings.Cut(line, "="); ok {
+		d.fields[key] = value
+	}
+	return Log{}, false
+}
+
+// Read returns the next entry of the export read from r, io.EOF once r
+// ended. Binary values are read by their length, so they can hold any byte.
+func (d *JournalExportDecoder) Read(r *bufio.Reader) (Log, error) {
+	for {
+		line, err := r.ReadString('\n')
+		if errors.Is(err, io.EOF) && line == "" {
+			// the last entry may not be followed by an empty line
+			if l, ok := d.entry(); ok {
+				return l, nil
+			}
+			return Log{}, io.EOF
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return Log{}, err
+		}
+
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			if l, ok := d.entry(); ok {
+				return l, nil
+			}
+			continue
+		}
+		if key, value, ok := strings.Cut(line, "="); ok {
+			d.fields[key] = value
+			continue
+		}
+
+		value, err := readBinaryValue(r)
+		if err != nil {
+			return Log{}, fmt.Errorf("failed to read journal field %s: %w", line, err)
+		}
+		d.fields[line] = value
+	}
+}
+
+// entry returns the log of the fields read so far, if any.
+func (d *JournalExportDecoder) entry() (Log, bool) {
+	if len(d.fields) == 0 {
+		return Log{}, false
+	}
+	l := journalEntry(d.fields)
+	d.fields = map[string]string{}
+	return l, true
+}
+
+// readBinaryValue reads a length, the value of that length and the newline
+// ending it.
+func readBinaryValue(r *bufio.Reader) (string, error) {
+	var length uint64
+	if err := binary.Read(r, binary.LittleEndian, &length); err != nil {
+		return "", unexpectedEOF(err)
+	}
+	if length > math.MaxInt64 {
+		return "", fmt.Errorf("invalid length %d", length)
+	}
+
+	var value strings.Builder
+	if _, err := io.CopyN(&value, r, int64(length)); err != nil {
+		return "", unexpectedEOF(err)
+	}
+	if newline, err := r.ReadByte(); err != nil {
+		return "", unexpectedEOF(err)
+	} else if newline != '\n' {
+		return "", fmt.Errorf("expected a newline after %d bytes", length)
+	}
+	return value.String(), nil
+}
+
+// unexpectedEOF reports the end of the input in the middle of a field as an
+// error.
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// JournalJSONDecoder reads the entries written by `journalctl -o json`, one
+// JSON object per line. Lines that are not JSON objects are kept as they are.
+type JournalJSONDecoder struct{}
+
+func isJournalJSONRecord(line string) bool {
+	return strings.HasPrefix(line, `{"__CURSOR":`)
+}
+
+func (JournalJSONDecoder) Decode(line string) (Log, bool) {
+	var record map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(line), &record); err != nil {
+		return NewLog(line), true
+	}
+
+	fields := map[string]string{}
+	for key, raw := range record {
+		if value, ok := journalJSONValue(raw); ok {
+			fields[key] = value
+		}
+	}
+	return journalEntry(fields), true
+}
+
+// journalJSONValue decodes a field of a JSON entry: a string, an array of
+// bytes for values that are not printable, null for values too large, or an
+// array of those when the field has several values.
+func journalJSONValue(raw json.RawMessage) (string, bool) {
+	if string(raw) == "null" {
+		return "", false
+	}
+
+	var value string
+	if err := json.Unmarshal(raw, &value); err == nil {
+		return value, true
+	}
+
+	// a JSON array of numbers is decoded element by element, not as base64
+	var bytes []byte
+	if err := json.Unmarshal(raw, &bytes); err == nil {
+		return string(bytes), true
+	}
+
+	var values []json.RawMessage
+	if err := json.Unmarshal(raw, &values); err == nil {
+		var decoded []string
+		for _, v := range values {
+			if value, ok := journalJSONValue(v); ok {
+				decoded = append(decoded, value)
+			}
+		}
+		return strings.Join(decoded, "\n"), len(decoded) > 0
+	}
+
+	return "", false
+}
+
+// journalEntry builds the log for the fields of a journal entry. MESSAGE is
+// the line, PRIORITY the level, and __REALTIME_TIMESTAMP the timestamp. The
+// other fields are kept, except for the ones used to address the entries in
+// the journal (starting with a double underscore).
+func journalEntry(fields map[string]string) Log {
+	l := NewLog(fields["MESSAGE"])
+
+	if usec, err := strconv.ParseInt(fields["__REALTIME_TIMESTAMP"], 10, 64); err == nil {
+		l.Timestamp = time.UnixMicro(usec)
+	}
+	if priority, err := strconv.Atoi(fields["PRIORITY"]); err == nil {
+		l.Level = LevelFromPriority(priority)
+	}
+
+	l.Fields = map[string]string{}
+	for key, value := range fields {
+		if key != "MESSAGE" && !strings.HasPrefix(key, "__") {
+			l.Fields[key] = value
+		}
+	}
+	return l
+}
@@ -0,0 +1,148 @@
+package logentry_test
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/carlo-colombo/streamlog_go/logentry"
+)
+
+var _ = Describe("Journal", func() {
+	Describe("export format", func() {
+		decodeStream := func(stream string) []logentry.Log {
+			decoder := logentry.NewJournalExportDecoder()
+			r := bufio.NewReader(strings.NewReader(stream))
+
+			var logs []logentry.Log
+			for {
+				l, err := decoder.Read(r)
+				if err == io.EOF {
+					return logs
+				}
+				Expect(err).NotTo(HaveOccurred())
+				logs = append(logs, l)
+			}
+		}
+
+		binaryField := func(key, value string) string {
+			length := make([]byte, 8)
+			binary.LittleEndian.PutUint64(length, uint64(len(value)))
+			return key + "\n" + string(length) + value + "\n"
+		}
+
+		It("reads an entry per block of fields", func() {
+			logs := decodeStream(`__CURSOR=s=1
+__REALTIME_TIMESTAMP=1704103200000000
+PRIORITY=3
+_SYSTEMD_UNIT=nginx.service
+_PID=42
+MESSAGE=upstream timed out
+
+__CURSOR=s=2
+MESSAGE=second entry
+
+`)
+
+			Expect(logs).To(HaveExactElements(
+				SatisfyAll(
+					HaveField("Line", "upstream timed out"),
+					HaveField("Level", "error"),
+					HaveField("Timestamp", BeTemporally("==", time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC))),
+					HaveField("Fields", Equal(map[string]string{
+						"PRIORITY":      "3",
+						"_SYSTEMD_UNIT": "nginx.service",
+						"_PID":          "42",
+					})),
+				),
+				SatisfyAll(
+					HaveField("Line", "second entry"),
+					HaveField("Level", ""),
+				),
+			))
+		})
+
+		It("reads binary fields, including newlines and their length", func() {
+			message := "line one\nline two\x01"
+			length := make([]byte, 8)
+			binary.LittleEndian.PutUint64(length, uint64(len(message)))
+
+			// a length of 10 is written as a newline
+			ten := "0123456789"
+			tenLength := make([]byte, 8)
+			binary.LittleEndian.PutUint64(tenLength, 10)
+
+			logs := decodeStream("PRIORITY=6\nMESSAGE\n" + string(length) + message + "\n" +
+				"OTHER\n" + string(tenLength) + ten + "\n\n")
+
+			Expect(logs).To(HaveExactElements(SatisfyAll(
+				HaveField("Line", message),
+				HaveField("Level", "info"),
+				HaveField("Fields", HaveKeyWithValue("OTHER", ten)),
+			)))
+		})
+
+		It("reads binary values holding carriage returns and longer than 64KiB", func() {
+			message := "first\r\nsecond\r\n"
+			long := strings.Repeat("x\r\n", 30000)
+
+			logs := decodeStream(binaryField("MESSAGE", message) + binaryField("LONG", long) +
+				"PRIORITY=6\n\nMESSAGE=next\n")
+
+			Expect(logs).To(HaveExactElements(
+				SatisfyAll(
+					HaveField("Line", message),
+					HaveField("Level", "info"),
+					HaveField("Fields", HaveKeyWithValue("LONG", long)),
+				),
+				HaveField("Line", "next"),
+			))
+		})
+
+		It("fails on an input ending inside a binary value", func() {
+			decoder := logentry.NewJournalExportDecoder()
+			field := binaryField("MESSAGE", "truncated")
+
+			_, err := decoder.Read(bufio.NewReader(strings.NewReader(field[:len(field)-4])))
+
+			Expect(err).To(MatchError(io.ErrUnexpectedEOF))
+		})
+	})
+
+	Describe("JSON format", func() {
+		It("reads an entry per line", func() {
+			l, ok := logentry.JournalJSONDecoder{}.Decode(
+				`{"__CURSOR":"s=1","__REALTIME_TIMESTAMP":"1704103200000000","PRIORITY":"4","_SYSTEMD_UNIT":"cron.service","_PID":"7","MESSAGE":"disk almost full","BINARY":[104,105],"MULTI":["a","b"],"LARGE":null}`)
+
+			Expect(ok).To(BeTrue())
+			Expect(l.Line).To(Equal("disk almost full"))
+			Expect(l.Level).To(Equal("warning"))
+			Expect(l.Timestamp).To(BeTemporally("==", time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)))
+			Expect(l.Fields).To(Equal(map[string]string{
+				"PRIORITY":      "4",
+				"_SYSTEMD_UNIT": "cron.service",
+				"_PID":          "7",
+				"BINARY":        "hi",
+				"MULTI":         "a\nb",
+			}))
+		})
+
+		It("decodes messages that are not printable", func() {
+			l, _ := logentry.JournalJSONDecoder{}.Decode(`{"MESSAGE":[104,105,255]}`)
+
+			Expect(l.Line).To(Equal("hi\xff"))
+		})
+
+		It("keeps other lines as they are", func() {
+			l, ok := logentry.JournalJSONDecoder{}.Decode("not json")
+
+			Expect(ok).To(BeTrue())
+			Expect(l.Line).To(Equal("not json"))
+		})
+	})
+})
@@ -0,0 +1,14 @@
+package logentry
+
+// Levels are the syslog severities, from the most to the least severe, as
+// used for the priority of syslog and journald messages.
+var Levels = []string{"emergency", "alert", "critical", "error", "warning", "notice", "info", "debug"}
+
+// LevelFromPriority returns the level for a syslog severity or journald
+// priority, an empty string when it is out of range.
+func LevelFromPriority(priority int) string {
+	if priority < 0 || priority >= len(Levels) {
+		return ""
+	}
+	return Levels[priority]
+}
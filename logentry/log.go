@@ -10,8 +10,19 @@ type Encoder interface {
 }
 
 type Log struct {
-	Line      string    `json:"line"`
-	Timestamp time.Time `json:"timestamp"`
+	ID        int64             `json:"id,omitempty"`
+	Line      string            `json:"line"`
+	Timestamp time.Time         `json:"timestamp"`
+	Source    string            `json:"source,omitempty"`
+	Level     string            `json:"level,omitempty"`
+	Fields    map[string]string `json:"fields,omitempty"`
+	// Repeat counts the consecutive repeats collapsed into this log, the
+	// last of them at LastTimestamp. Both are zero for logs never repeated.
+	Repeat        int       `json:"repeat,omitempty"`
+	LastTimestamp time.Time `json:"last_timestamp,omitzero"`
+	// Update is set on the logs sent again to the clients because their
+	// Repeat grew, never stored.
+	Update bool `json:"-"`
 }
 
 func NewLog(line string) Log {
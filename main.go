@@ -8,29 +8,343 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/carlo-colombo/streamlog_go/logentry"
+	"github.com/carlo-colombo/streamlog_go/pipeline"
+	"github.com/carlo-colombo/streamlog_go/redact"
+	"github.com/carlo-colombo/streamlog_go/source"
+	"github.com/carlo-colombo/streamlog_go/syslog"
 )
 
+// repeatedFlag collects the values of a flag that can be repeated.
+type repeatedFlag []string
+
+func (f *repeatedFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *repeatedFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
 func main() {
+	var files, imports, redactRules, drops, addFields, samples, rateLimits repeatedFlag
+	flag.Var(&imports, "import", "NDJSON export (compressed or not) to store before reading the inputs, can be repeated")
+	flag.Var(&files, "file", "file to follow (or read once if compressed) instead of reading stdin, can be repeated")
+	flag.Var(&drops, "drop", "regexp of the lines not to store, can be repeated")
+	flag.Var(&samples, "sample", "N or N:regexp, keep 1 in N lines (matching regexp) of every source, can be repeated")
+	flag.Var(&rateLimits, "rate-limit", "N or N:regexp, keep at most N lines per second (matching regexp) of every source, can be repeated")
+	limitWindow := flag.Duration("limit-window", 10*time.Second, "how often to store how many lines --sample and --rate-limit suppressed")
+	flag.Var(&addFields, "add-field", "key=value field added to every log, can be repeated")
+	flag.Var(&redactRules, "redact-rule", "name=/regexp/ of secrets to redact (only the group named secret if any), can be repeated")
 	port := flag.String("port", "0", "port")
 	dbPath := flag.String("db", ":memory:", "path to SQLite database file (default: in-memory)")
+	batchSize := flag.Int("batch-size", DefaultBatchSize, "number of logs written in a single transaction")
+	batchInterval := flag.Duration("batch-interval", DefaultBatchInterval, "how long a log waits for its batch to fill before being written")
+	exitOnEOF := flag.Bool("exit-on-eof", false, "exit once all the inputs ended (stdin, files, the command, listeners, syslog) instead of keeping the UI running")
+	tee := flag.Bool("tee", false, "copy the ingested lines to stdout")
+	teeMatch := flag.String("tee-match", "", "with --tee, only copy the lines matching this regular expression")
+	tcpListen := flag.String("tcp-listen", "", "address to accept newline-delimited logs on over TCP (e.g. :9000)")
+	unixListen := flag.String("unix-listen", "", "path of a unix socket to accept newline-delimited logs on")
+	format := flag.String("format", "raw", "format of the lines read from the inputs: "+strings.Join(logentry.Formats, ", "))
+	redactBuiltin := flag.Bool("redact", false, "redact bearer tokens, passwords in URLs and credit card numbers")
+	collapse := flag.String("collapse", "off", "collapse consecutive repeats of a line: off, exact or masked (ignoring numbers, UUIDs and hex values)")
+	binary := flag.String("binary", "replace", "how invalid UTF-8 and control characters are stored: replace, escape (as \\xNN) or base64")
+	syslogListen := flag.String("syslog-listen", "", "address to receive syslog messages on, over UDP and TCP (e.g. :5514)")
+	readonly := flag.Bool("readonly", false, "browse the logs stored in --db without ingesting any")
+	partition := flag.String("partition", "off", "split the logs in a --db file per period: off, hour or day (e.g. logs-2026-10-18.db)")
+	retention := flag.Duration("retention", 0, "with --partition, remove the partitions that ended longer ago than this (default: keep all)")
+	archiveAfter := flag.Duration("archive-after", 0, "with --partition, compress the partitions that ended longer ago than this (default: never)")
+	snapshotDir := flag.String("snapshot-dir", ".", "directory of the snapshots written by POST /api/snapshot, SIGUSR1 and --snapshot-on-exit")
+	snapshotOnExit := flag.Bool("snapshot-on-exit", false, "write a snapshot of the database in --snapshot-dir when exiting")
 	flag.Parse()
 
-	store, err := NewSQLiteStore(*dbPath)
+	period, err := ParsePartitionPeriod(*partition)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if period != PartitionOff && *dbPath == ":memory:" {
+		log.Fatal("--partition needs the --db to split")
+	}
+	if *retention != 0 && period == PartitionOff {
+		log.Fatal("--retention needs --partition")
+	}
+	if *archiveAfter != 0 && period == PartitionOff {
+		log.Fatal("--archive-after needs --partition")
+	}
+
+	if *readonly {
+		if period != PartitionOff {
+			log.Fatal("--readonly cannot be combined with --partition, browse a partition with --db <partition>")
+		}
+		if *dbPath == ":memory:" {
+			log.Fatal("--readonly needs the --db to browse")
+		}
+		if flag.NArg() > 0 || len(files) > 0 || len(imports) > 0 || *tcpListen != "" || *unixListen != "" || *syslogListen != "" {
+			log.Fatal("--readonly cannot be combined with inputs")
+		}
+		store, err := NewReadOnlySQLiteStore(*dbPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer store.Close()
+		go snapshotOnSignal(store, *snapshotDir)
+		serve(store, *port, *snapshotDir, nil, false)
+		return
+	}
+
+	store, err := NewPartitionedSQLiteStore(*dbPath, Partitioning{
+		Period:       period,
+		Retention:    *retention,
+		ArchiveAfter: *archiveAfter,
+	}, time.Now)
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer store.Close()
+	store.SetBatch(*batchSize, *batchInterval)
+	if *snapshotOnExit {
+		store.SetSnapshotOnClose(*snapshotDir)
+	}
+	go snapshotOnSignal(store, *snapshotDir)
 
-	go store.Scan(os.Stdin)
+	newDecoder, err := logentry.NewDecoderFactory(*format)
+	if err != nil {
+		log.Fatal(err)
+	}
+	store.SetDecoder(newDecoder)
+
+	binaryPolicy, err := logentry.ParseBinaryPolicy(*binary)
+	if err != nil {
+		log.Fatal(err)
+	}
+	store.SetBinaryPolicy(binaryPolicy)
+
+	collapseMode, err := logentry.ParseCollapseMode(*collapse)
+	if err != nil {
+		log.Fatal(err)
+	}
+	store.SetCollapse(collapseMode)
+
+	var processors []pipeline.Processor
+	for _, pattern := range drops {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			log.Fatalf("Invalid --drop pattern: %v", err)
+		}
+		processors = append(processors, pipeline.Drop(re))
+	}
+
+	var limiters int
+	for _, limits := range []struct {
+		kind  string
+		specs []string
+	}{{"every", samples}, {"rate", rateLimits}} {
+		for _, spec := range limits.specs {
+			limit, err := pipeline.ParseLimit(limits.kind, spec, *limitWindow)
+			if err != nil {
+				log.Fatal(err)
+			}
+			processors = append(processors, pipeline.NewLimiter(limit, time.Now))
+			limiters++
+		}
+	}
+
+	var redactor *redact.Redactor
+	if *redactBuiltin || len(redactRules) > 0 {
+		var rules []redact.Rule
+		if *redactBuiltin {
+			rules = redact.Builtin()
+		}
+		for _, spec := range redactRules {
+			rule, err := redact.ParseRule(spec)
+			if err != nil {
+				log.Fatal(err)
+			}
+			rules = append(rules, rule)
+		}
+		redactor = redact.New(rules...)
+		processors = append(processors, pipeline.Redact(redactor))
+		store.SetImportPipeline(pipeline.Redact(redactor))
+	}
+
+	if len(addFields) > 0 {
+		fields := make(map[string]string, len(addFields))
+		for _, field := range addFields {
+			key, value, ok := strings.Cut(field, "=")
+			if !ok || key == "" {
+				log.Fatalf("Invalid --add-field %q, expected key=value", field)
+			}
+			fields[key] = value
+		}
+		processors = append(processors, pipeline.Enrich(fields))
+	}
+	store.SetPipeline(processors...)
+	if limiters > 0 {
+		go func() {
+			for range time.Tick(time.Second) {
+				store.FlushPipeline()
+			}
+		}()
+	}
+
+	if *tee {
+		var match *regexp.Regexp
+		if *teeMatch != "" {
+			match, err = regexp.Compile(*teeMatch)
+			if err != nil {
+				log.Fatalf("Invalid --tee-match pattern: %v", err)
+			}
+		}
+		store.SetTee(NewTee(os.Stdout, match))
+	}
+
+	for _, path := range imports {
+		if err := importFile(store, path); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// --exit-on-eof waits for all the inputs, listeners and the syslog server
+	// end when closed
+	var inputs sync.WaitGroup
+
+	for network, addr := range map[string]string{"tcp": *tcpListen, "unix": *unixListen} {
+		if addr == "" {
+			continue
+		}
+		listener, err := source.Listen(network, addr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		store.StartInput()
+		inputs.Add(1)
+		go func() {
+			defer inputs.Done()
+			listener.Serve(store.ScanConnection)
+			store.StopInput(nil)
+		}()
+	}
 
+	if *syslogListen != "" {
+		server, err := syslog.Listen(*syslogListen)
+		if err != nil {
+			log.Fatal(err)
+		}
+		store.StartInput()
+		inputs.Add(1)
+		go func() {
+			defer inputs.Done()
+			server.Serve(store.Ingest)
+			store.StopInput(nil)
+		}()
+	}
+
+	// while a command runs the signals are forwarded to it
+	commandDone := make(chan struct{})
+	// the exit status of the command, --exit-on-eof exits with it
+	var code int
+	switch {
+	case flag.NArg() > 0:
+		cmd, err := source.StartCommand(flag.Args())
+		if err != nil {
+			log.Fatal(err)
+		}
+		inputs.Add(1)
+		go func() {
+			defer inputs.Done()
+			code = scanCommand(store, cmd)
+			close(commandDone)
+		}()
+	case len(files) > 0:
+		for _, path := range files {
+			file, err := source.Open(path, 250*time.Millisecond)
+			if err != nil {
+				log.Fatal(err)
+			}
+			inputs.Add(1)
+			go func() {
+				defer inputs.Done()
+				store.ScanSource(file, path)
+			}()
+		}
+		close(commandDone)
+	default:
+		inputs.Add(1)
+		go func() {
+			defer inputs.Done()
+			scanStdin(store)
+		}()
+		close(commandDone)
+	}
+	if *exitOnEOF {
+		go func() {
+			inputs.Wait()
+			exitAfterInputs(store, code)
+		}()
+	}
+	// the logs waiting for their batch are written before exiting
+	go closeOnSignal(store, commandDone)
+
+	serve(store, *port, *snapshotDir, redactor, true)
+}
+
+// snapshotOnSignal writes a snapshot of the store in dir on every SIGUSR1.
+func snapshotOnSignal(store *SQLiteLogsStore, dir string) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1)
+
+	for range signals {
+		path := SnapshotPath(dir, time.Now())
+		if err := store.Snapshot(path); err != nil {
+			log.Printf("Failed to write snapshot: %v", err)
+			continue
+		}
+		log.Printf("Wrote snapshot %s", path)
+	}
+}
+
+// closeOnSignal closes the store, which writes the pending logs and the
+// snapshot, and exits on SIGINT and SIGTERM once ready is closed.
+func closeOnSignal(store *SQLiteLogsStore, ready <-chan struct{}) {
+	<-ready
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	sig := <-signals
+	_ = store.Close()
+	os.Exit(128 + int(sig.(syscall.Signal)))
+}
+
+// serve serves the UI and the APIs over store, the ingestion API only when
+// ingest is set. Snapshots are written in snapshotDir.
+func serve(store *SQLiteLogsStore, port, snapshotDir string, redactor *redact.Redactor, ingest bool) {
 	fsys, _ := fs.Sub(static, "app/dist/app/browser")
 
 	http.Handle("/", http.FileServer(http.FS(fsys)))
 	http.HandleFunc("/clients", ClientsHandler(store))
 	http.HandleFunc("/logs", LogsHandler(store))
 	http.HandleFunc("/filter", FilterHandler(store))
+	http.HandleFunc("/status", StatusHandler(store))
+	http.HandleFunc("/sources", SourcesHandler(store))
+	http.HandleFunc("/api/export", ExportHandler(store))
+	http.HandleFunc("/api/snapshot", SnapshotHandler(store, snapshotDir))
+	if ingest {
+		http.HandleFunc("/ingest", IngestHandler(store))
+		http.HandleFunc("/api/import", ImportHandler(store))
+	}
+	http.HandleFunc("/redactions", RedactionsHandler(redactor))
+	http.HandleFunc("/metrics", MetricsHandler(store))
 
-	listener, err := net.Listen("tcp", fmt.Sprintf(":%s", *port))
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%s", port))
 	if err != nil {
 		log.Fatalf("Failed to start server: %v", err)
 	}
@@ -44,3 +358,79 @@ func main() {
 	err = http.Serve(listener, nil)
 	log.Fatal(err)
 }
+
+// importFile stores the logs of the export at path.
+func importFile(store *SQLiteLogsStore, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	r, err := source.Decompress(file)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	defer r.Close()
+
+	count, err := store.Import(r)
+	if err != nil {
+		return fmt.Errorf("failed to import %s after %d logs: %w", path, count, err)
+	}
+	log.Printf("Imported %d logs from %s", count, path)
+	return nil
+}
+
+func scanStdin(store *SQLiteLogsStore) {
+	stdin, err := source.Decompress(os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+	store.Scan(stdin)
+}
+
+// exitAfterInputs closes the store and exits with code once all the inputs
+// ended, with status 1 if reading one of them failed.
+func exitAfterInputs(store *SQLiteLogsStore, code int) {
+	status := store.Status()
+	_ = store.Close()
+	if status.State == IngestionError {
+		log.Fatalf("Failed to read input: %s", status.Error)
+	}
+	os.Exit(code)
+}
+
+// scanCommand ingests the output of a child command, stdout and stderr as two
+// separate sources, followed by a line recording its exit code, which is
+// returned.
+func scanCommand(store *SQLiteLogsStore, cmd *source.Command) int {
+	// the command is running until its exit code is recorded
+	store.StartInput()
+
+	stop := cmd.ForwardSignals(syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		store.ScanSource(cmd.Stdout, cmd.Name+":stdout")
+	}()
+	go func() {
+		defer wg.Done()
+		store.ScanSource(cmd.Stderr, cmd.Name+":stderr")
+	}()
+	wg.Wait()
+
+	code, err := cmd.Wait()
+	stop()
+	if err != nil {
+		log.Print(err)
+		code = 1
+	}
+
+	exit := logentry.NewLog(fmt.Sprintf("%s exited with status %d", cmd.Name, code))
+	exit.Source = cmd.Name
+	store.Record(exit)
+	store.StopInput(nil)
+	return code
+}
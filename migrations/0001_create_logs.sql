@@ -0,0 +1,5 @@
+CREATE TABLE logs (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	line TEXT NOT NULL,
+	timestamp DATETIME NOT NULL
+);
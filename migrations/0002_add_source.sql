@@ -0,0 +1 @@
+ALTER TABLE logs ADD COLUMN source TEXT NOT NULL DEFAULT '';
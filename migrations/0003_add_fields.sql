@@ -0,0 +1 @@
+ALTER TABLE logs ADD COLUMN fields TEXT NOT NULL DEFAULT '';
@@ -0,0 +1 @@
+ALTER TABLE logs ADD COLUMN level TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+ALTER TABLE logs ADD COLUMN repeat INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE logs ADD COLUMN last_timestamp DATETIME;
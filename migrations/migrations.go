@@ -0,0 +1,190 @@
+// Package migrations upgrades the schema of the databases the logs are
+// stored in. Every migration is a SQL file named <version>_<name>.sql, applied
+// in order of version and recorded in the schema_version table.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed *.sql
+var files embed.FS
+
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// All returns the migrations in the order they are applied.
+func All() ([]Migration, error) {
+	names, err := fs.Glob(files, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := make([]Migration, 0, len(names))
+	for _, name := range names {
+		prefix, rest, ok := strings.Cut(strings.TrimSuffix(name, ".sql"), "_")
+		version, err := strconv.Atoi(prefix)
+		if !ok || err != nil {
+			return nil, fmt.Errorf("invalid migration name %q, expected <version>_<name>.sql", name)
+		}
+		data, err := files.ReadFile(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", name, err)
+		}
+		migrations = append(migrations, Migration{Version: version, Name: rest, SQL: string(data)})
+	}
+
+	slices.SortFunc(migrations, func(a, b Migration) int { return a.Version - b.Version })
+	return migrations, nil
+}
+
+// Latest returns the version of the schema once all the migrations are
+// applied.
+func Latest() int {
+	migrations, err := All()
+	if err != nil || len(migrations) == 0 {
+		return 0
+	}
+	return migrations[len(migrations)-1].Version
+}
+
+// Version returns the version of the schema of db, 0 for an empty database.
+func Version(ctx context.Context, db *sql.DB) (int, error) {
+	var versioned bool
+	err := db.QueryRowContext(ctx,
+		"SELECT COUNT(*) > 0 FROM sqlite_master WHERE type = 'table' AND name = 'schema_version'",
+	).Scan(&versioned)
+	if err != nil {
+		return 0, fmt.Errorf("failed to inspect schema: %w", err)
+	}
+	if !versioned {
+		return legacyVersion(ctx, db)
+	}
+
+	var version int
+	err = db.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_version").Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	if version == 0 {
+		// interrupted before recording the version of a legacy database
+		return legacyVersion(ctx, db)
+	}
+	return version, nil
+}
+
+// legacyColumns are the columns added to the logs table before the schema
+// was versioned, by the migration adding them.
+var legacyColumns = []struct {
+	version int
+	column  string
+}{
+	{5, "last_timestamp"},
+	{4, "level"},
+	{3, "fields"},
+	{2, "source"},
+	{1, "id"},
+}
+
+// legacyVersion tells the version of the databases created before the
+// schema was versioned by the columns of their logs table.
+func legacyVersion(ctx context.Context, db *sql.DB) (int, error) {
+	for _, legacy := range legacyColumns {
+		var exists bool
+		err := db.QueryRowContext(ctx,
+			"SELECT COUNT(*) > 0 FROM pragma_table_info('logs') WHERE name = ?", legacy.column,
+		).Scan(&exists)
+		if err != nil {
+			return 0, fmt.Errorf("failed to inspect table logs: %w", err)
+		}
+		if exists {
+			return legacy.version, nil
+		}
+	}
+	return 0, nil
+}
+
+// Migrate applies the migrations db misses, each in its own transaction. It
+// fails on databases created by a newer version.
+func Migrate(ctx context.Context, db *sql.DB) error {
+	migrations, err := All()
+	if err != nil {
+		return err
+	}
+
+	current, err := Version(ctx, db)
+	if err != nil {
+		return err
+	}
+	if latest := Latest(); current > latest {
+		return fmt.Errorf("database schema version %d is newer than the supported version %d", current, latest)
+	}
+
+	_, err = db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_version (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at DATETIME NOT NULL
+		)`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_version table: %w", err)
+	}
+
+	for _, migration := range migrations {
+		if err := apply(ctx, db, migration, migration.Version <= current); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// apply runs migration, unless already applied before the schema was
+// versioned, and records it.
+func apply(ctx context.Context, db *sql.DB, migration Migration, applied bool) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var recorded bool
+	err = tx.QueryRowContext(ctx,
+		"SELECT COUNT(*) > 0 FROM schema_version WHERE version = ?", migration.Version,
+	).Scan(&recorded)
+	if err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+	if recorded {
+		return nil
+	}
+
+	if !applied {
+		if _, err := tx.ExecContext(ctx, migration.SQL); err != nil {
+			return fmt.Errorf("failed to apply migration %d %s: %w", migration.Version, migration.Name, err)
+		}
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO schema_version (version, name, applied_at) VALUES (?, ?, ?)",
+		migration.Version, migration.Name, time.Now(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record migration %d %s: %w", migration.Version, migration.Name, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration %d %s: %w", migration.Version, migration.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package migrations_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+
+	"github.com/carlo-colombo/streamlog_go/migrations"
+	_ "github.com/mattn/go-sqlite3"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Migrate", func() {
+	var (
+		ctx context.Context
+		db  *sql.DB
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		var err error
+		db, err = sql.Open("sqlite3", filepath.Join(GinkgoT().TempDir(), "logs.db"))
+		Expect(err).ToNot(HaveOccurred())
+		DeferCleanup(db.Close)
+	})
+
+	columns := func() []string {
+		rows, err := db.Query("SELECT name FROM pragma_table_info('logs') ORDER BY cid")
+		Expect(err).ToNot(HaveOccurred())
+		defer rows.Close()
+
+		var names []string
+		for rows.Next() {
+			var name string
+			Expect(rows.Scan(&name)).To(Succeed())
+			names = append(names, name)
+		}
+		return names
+	}
+
+	latestColumns := []string{"id", "line", "timestamp", "source", "fields", "level", "repeat", "last_timestamp"}
+
+	It("orders the migrations by version", func() {
+		all, err := migrations.All()
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(all).To(HaveLen(migrations.Latest()))
+		for i, migration := range all {
+			Expect(migration.Version).To(Equal(i + 1))
+		}
+		Expect(all[0].Name).To(Equal("create_logs"))
+	})
+
+	It("creates the schema of an empty database", func() {
+		Expect(migrations.Version(ctx, db)).To(Equal(0))
+
+		Expect(migrations.Migrate(ctx, db)).To(Succeed())
+
+		Expect(migrations.Version(ctx, db)).To(Equal(migrations.Latest()))
+		Expect(columns()).To(Equal(latestColumns))
+	})
+
+	It("does nothing on an up to date database", func() {
+		Expect(migrations.Migrate(ctx, db)).To(Succeed())
+		_, err := db.Exec("INSERT INTO logs (line, timestamp) VALUES ('kept', CURRENT_TIMESTAMP)")
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(migrations.Migrate(ctx, db)).To(Succeed())
+
+		var count int
+		Expect(db.QueryRow("SELECT COUNT(*) FROM schema_version").Scan(&count)).To(Succeed())
+		Expect(count).To(Equal(migrations.Latest()))
+		Expect(db.QueryRow("SELECT COUNT(*) FROM logs").Scan(&count)).To(Succeed())
+		Expect(count).To(Equal(1))
+	})
+
+	DescribeTable("upgrades databases created before the schema was versioned",
+		func(schema string, version int) {
+			_, err := db.Exec(schema)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = db.Exec("INSERT INTO logs (line, timestamp) VALUES ('old line', CURRENT_TIMESTAMP)")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(migrations.Version(ctx, db)).To(Equal(version))
+
+			Expect(migrations.Migrate(ctx, db)).To(Succeed())
+
+			Expect(migrations.Version(ctx, db)).To(Equal(migrations.Latest()))
+			Expect(columns()).To(Equal(latestColumns))
+			var line, source string
+			var repeat int
+			Expect(db.QueryRow("SELECT line, source, repeat FROM logs").Scan(&line, &source, &repeat)).To(Succeed())
+			Expect([]any{line, source, repeat}).To(Equal([]any{"old line", "", 1}))
+		},
+		Entry("with lines only", `CREATE TABLE logs (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			line TEXT NOT NULL,
+			timestamp DATETIME NOT NULL
+		)`, 1),
+		Entry("with sources", `CREATE TABLE logs (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			line TEXT NOT NULL,
+			timestamp DATETIME NOT NULL,
+			source TEXT NOT NULL DEFAULT ''
+		)`, 2),
+		Entry("with levels", `CREATE TABLE logs (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			line TEXT NOT NULL,
+			timestamp DATETIME NOT NULL,
+			source TEXT NOT NULL DEFAULT '',
+			fields TEXT NOT NULL DEFAULT '',
+			level TEXT NOT NULL DEFAULT ''
+		)`, 4),
+	)
+
+	It("refuses databases created by a newer version", func() {
+		Expect(migrations.Migrate(ctx, db)).To(Succeed())
+		_, err := db.Exec("INSERT INTO schema_version (version, name, applied_at) VALUES (999, 'future', CURRENT_TIMESTAMP)")
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(migrations.Migrate(ctx, db)).To(MatchError(ContainSubstring("schema version 999 is newer")))
+	})
+})
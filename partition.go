@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	stdlog "log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/carlo-colombo/streamlog_go/logentry"
+)
+
+// PartitionPeriod is how long the logs are written to the same partition, a
+// database file of its own.
+type PartitionPeriod string
+
+const (
+	// PartitionOff writes all the logs to a single database.
+	PartitionOff PartitionPeriod = "off"
+	// PartitionHour starts a new partition every hour.
+	PartitionHour PartitionPeriod = "hour"
+	// PartitionDay starts a new partition every day.
+	PartitionDay PartitionPeriod = "day"
+)
+
+var PartitionPeriods = []PartitionPeriod{PartitionOff, PartitionHour, PartitionDay}
+
+func ParsePartitionPeriod(s string) (PartitionPeriod, error) {
+	for _, period := range PartitionPeriods {
+		if string(period) == s {
+			return period, nil
+		}
+	}
+	return "", fmt.Errorf("unknown partition period %q, expected one of off, hour, day", s)
+}
+
+// start returns the start of the partition t falls in, partitions follow
+// UTC.
+func (p PartitionPeriod) start(t time.Time) time.Time {
+	t = t.UTC()
+	if p == PartitionHour {
+		return t.Truncate(time.Hour)
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// end returns the start of the partition after the one starting at start.
+func (p PartitionPeriod) end(start time.Time) time.Time {
+	if p == PartitionHour {
+		return start.Add(time.Hour)
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+// layout is how the start of a partition is written in its name.
+func (p PartitionPeriod) layout() string {
+	if p == PartitionHour {
+		return "2006-01-02T15"
+	}
+	return "2006-01-02"
+}
+
+// Partitioning is how the logs are split in database files.
+type Partitioning struct {
+	Period PartitionPeriod
+	// Retention is how long the partitions are kept once ended, forever
+	// when zero.
+	Retention time.Duration
+	// ArchiveAfter is how long after they ended the partitions are archived
+	// in compressed segments, never when zero.
+	ArchiveAfter time.Duration
+}
+
+// partitions are the database files the logs are written to, one per period
+// named after the path of the database: logs.db is split in
+// logs-2026-10-18.db, logs-2026-10-19.db, ...
+type partitions struct {
+	Partitioning
+	stem, ext string
+	// current is the start of the partition the logs are written to
+	current time.Time
+	// archiving is held while partitions are archived
+	archiving sync.Mutex
+}
+
+// partitionFile is a partition on disk, a database or, once archived, a
+// segment.
+type partitionFile struct {
+	start    time.Time
+	path     string
+	archived bool
+}
+
+func newPartitions(dbPath string, partitioning Partitioning) *partitions {
+	ext := filepath.Ext(dbPath)
+	return &partitions{
+		Partitioning: partitioning,
+		stem:         strings.TrimSuffix(dbPath, ext),
+		ext:          ext,
+	}
+}
+
+// path returns the path of the database of the partition starting at start.
+func (p *partitions) path(start time.Time) string {
+	return p.stem + "-" + start.Format(p.Period.layout()) + p.ext
+}
+
+// segmentPath returns the path of the partition starting at start once
+// archived.
+func (p *partitions) segmentPath(start time.Time) string {
+	return p.stem + "-" + start.Format(p.Period.layout()) + segmentExt
+}
+
+// list returns the partitions on disk, oldest first. A partition whose
+// archiving did not complete is listed as a database.
+func (p *partitions) list() ([]partitionFile, error) {
+	var files []partitionFile
+	for _, archived := range []bool{false, true} {
+		ext := p.ext
+		if archived {
+			ext = segmentExt
+		}
+		paths, err := filepath.Glob(p.stem + "-*" + ext)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list partitions: %w", err)
+		}
+
+		for _, path := range paths {
+			name := strings.TrimSuffix(strings.TrimPrefix(path, p.stem+"-"), ext)
+			// files of another period, or not partitions at all
+			start, err := time.Parse(p.Period.layout(), name)
+			if err != nil {
+				continue
+			}
+			if archived && slices.ContainsFunc(files, func(f partitionFile) bool { return f.start.Equal(start) }) {
+				continue
+			}
+			files = append(files, partitionFile{start: start, path: path, archived: archived})
+		}
+	}
+	slices.SortFunc(files, func(a, b partitionFile) int { return a.start.Compare(b.start) })
+	return files, nil
+}
+
+// open opens the partition starting at start, creating it if needed. The ids
+// of a new partition follow the ones of the partition before, so that they
+// keep increasing across partitions.
+func (p *partitions) open(ctx context.Context, counters *storeCounters, start time.Time) (*sql.DB, error) {
+	db, err := openDatabase(ctx, counters, p.path(start))
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := p.list()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	var lastID int64
+	if i := slices.IndexFunc(files, func(f partitionFile) bool { return f.start.Equal(start) }); i > 0 {
+		if lastID, err = p.lastID(ctx, counters, files[i-1]); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	err = retry(ctx, counters, func(ctx context.Context) error {
+		_, err := db.ExecContext(ctx, `
+			INSERT INTO sqlite_sequence (name, seq)
+			SELECT 'logs', ?
+			WHERE NOT EXISTS (SELECT 1 FROM sqlite_sequence WHERE name = 'logs')`, lastID)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to continue the ids of the previous partition: %w", err)
+	}
+	return db, nil
+}
+
+// lastID returns the last id given out by the partition in file.
+func (p *partitions) lastID(ctx context.Context, counters *storeCounters, file partitionFile) (int64, error) {
+	var lastID int64
+	if file.archived {
+		err := readSegment(file.path, func(logLine logentry.Log) error {
+			lastID = logLine.ID
+			return nil
+		})
+		if err != nil {
+			return 0, fmt.Errorf("failed to read the last id of partition %s: %w", file.path, err)
+		}
+		return lastID, nil
+	}
+
+	db, err := sql.Open("sqlite3", readOnlyDSN(file.path))
+	if err != nil {
+		return 0, fmt.Errorf("failed to open partition %s: %w", file.path, err)
+	}
+	defer db.Close()
+
+	err = retry(ctx, counters, func(ctx context.Context) error {
+		err := db.QueryRowContext(ctx, "SELECT seq FROM sqlite_sequence WHERE name = 'logs'").Scan(&lastID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to read the last id of partition %s: %w", file.path, err)
+	}
+	return lastID, nil
+}
+
+// migrate upgrades the schema of the partitions written by older versions,
+// so that they can be read along the current one. Segments do not need it.
+func (p *partitions) migrate(ctx context.Context, counters *storeCounters) error {
+	files, err := p.list()
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if file.archived {
+			continue
+		}
+		db, err := openDatabase(ctx, counters, file.path)
+		if err != nil {
+			return fmt.Errorf("failed to open partition %s: %w", file.path, err)
+		}
+		db.Close()
+	}
+	return nil
+}
+
+// dropExpired removes the partitions that ended more than the retention
+// before now. Nothing is removed without retention.
+func (p *partitions) dropExpired(now time.Time) {
+	if p.Retention <= 0 {
+		return
+	}
+
+	files, err := p.list()
+	if err != nil {
+		stdlog.Printf("Failed to drop expired partitions: %v", err)
+		return
+	}
+	for _, file := range files {
+		if !p.endedBefore(file.start, now.Add(-p.Retention)) {
+			continue
+		}
+		remove := removeDatabase
+		if file.archived {
+			remove = os.Remove
+		}
+		if err := remove(file.path); err != nil {
+			stdlog.Printf("Failed to drop partition %s: %v", file.path, err)
+			continue
+		}
+		stdlog.Printf("Dropped partition %s", file.path)
+	}
+}
+
+// archiveCold archives the partitions that ended more than ArchiveAfter
+// before now. Nothing is archived when it is zero.
+func (p *partitions) archiveCold(ctx context.Context, counters *storeCounters, now time.Time) {
+	if p.ArchiveAfter <= 0 {
+		return
+	}
+
+	p.archiving.Lock()
+	defer p.archiving.Unlock()
+
+	files, err := p.list()
+	if err != nil {
+		stdlog.Printf("Failed to archive partitions: %v", err)
+		return
+	}
+	for _, file := range files {
+		if file.archived || !p.endedBefore(file.start, now.Add(-p.ArchiveAfter)) {
+			continue
+		}
+		if err := p.archive(ctx, counters, file.start); err != nil {
+			stdlog.Printf("Failed to archive partition %s: %v", file.path, err)
+			continue
+		}
+		stdlog.Printf("Archived partition %s", p.segmentPath(file.start))
+	}
+}
+
+// endedBefore tells whether the partition starting at start ended before t,
+// which the current one never did.
+func (p *partitions) endedBefore(start, t time.Time) bool {
+	return !p.Period.end(start).After(t)
+}
+
+// removeDatabase removes the database file at path, with its WAL files.
+func removeDatabase(path string) error {
+	for _, file := range []string{path + "-wal", path + "-shm", path} {
+		if err := os.Remove(file); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package pipeline
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/carlo-colombo/streamlog_go/logentry"
+)
+
+// Flusher is implemented by the processors holding back entries, Flush
+// returns the ones due. Chain.Flush passes them through the processors
+// following the one that returned them.
+type Flusher interface {
+	Flush() []logentry.Log
+}
+
+// Flush collects the entries due from the processors of the chain.
+func (c Chain) Flush() []logentry.Log {
+	var entries []logentry.Log
+	for i, processor := range c {
+		flusher, ok := processor.(Flusher)
+		if !ok {
+			continue
+		}
+		for _, entry := range flusher.Flush() {
+			entries = append(entries, c[i+1:].Process(entry)...)
+		}
+	}
+	return entries
+}
+
+// Limit configures a Limiter. Either Every or Rate has to be set.
+type Limit struct {
+	// Match selects the entries to limit, all of them when nil.
+	Match *regexp.Regexp
+	// Every keeps one in Every entries.
+	Every int
+	// Rate keeps at most Rate entries per second, in bursts of up to Burst
+	// entries (Rate rounded up when zero).
+	Rate  float64
+	Burst int
+	// Window is how often a summary of the suppressed entries is emitted.
+	Window time.Duration
+}
+
+// ParseLimit parses the limits given as "N" or "N:regexp", N is the number
+// of entries set by kind, either "every" or "rate".
+func ParseLimit(kind, spec string, window time.Duration) (Limit, error) {
+	value, pattern, _ := strings.Cut(spec, ":")
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n <= 0 {
+		return Limit{}, fmt.Errorf("invalid limit %q, expected a positive number optionally followed by :regexp", spec)
+	}
+
+	limit := Limit{Window: window}
+	if pattern != "" {
+		if limit.Match, err = regexp.Compile(pattern); err != nil {
+			return Limit{}, fmt.Errorf("invalid limit pattern %q: %w", pattern, err)
+		}
+	}
+
+	switch kind {
+	case "every":
+		if n != math.Trunc(n) {
+			return Limit{}, fmt.Errorf("invalid limit %q, expected a whole number", spec)
+		}
+		limit.Every = int(n)
+	case "rate":
+		limit.Rate = n
+	default:
+		return Limit{}, fmt.Errorf("unknown limit kind %q, expected every or rate", kind)
+	}
+	return limit, nil
+}
+
+// Limiter suppresses part of the entries of noisy inputs, counting every
+// source separately. For every source that had entries suppressed, an entry
+// with their number is emitted once per window. Flush forgets the sources
+// idle for a whole window, once their suppressed entries are reported.
+type Limiter struct {
+	limit   Limit
+	now     func() time.Time
+	sources map[string]*limitState
+}
+
+type limitState struct {
+	seen        int
+	tokens      float64
+	refilled    time.Time
+	suppressed  int
+	windowStart time.Time
+	lastSeen    time.Time
+}
+
+// NewLimiter returns a limiter for limit, now is used to tell the time.
+func NewLimiter(limit Limit, now func() time.Time) *Limiter {
+	if limit.Rate > 0 && limit.Burst == 0 {
+		limit.Burst = int(math.Ceil(limit.Rate))
+	}
+	return &Limiter{limit: limit, now: now, sources: map[string]*limitState{}}
+}
+
+func (l *Limiter) Process(entry logentry.Log) []logentry.Log {
+	if l.limit.Match != nil && !l.limit.Match.MatchString(entry.Line) {
+		return []logentry.Log{entry}
+	}
+
+	now := l.now()
+	state, ok := l.sources[entry.Source]
+	if !ok {
+		state = &limitState{tokens: float64(l.limit.Burst), refilled: now, windowStart: now}
+		l.sources[entry.Source] = state
+	}
+	state.lastSeen = now
+
+	var entries []logentry.Log
+	if summary, ok := l.summary(entry.Source, state, now); ok {
+		entries = append(entries, summary)
+	}
+
+	if l.keep(state, now) {
+		return append(entries, entry)
+	}
+	state.suppressed++
+	return entries
+}
+
+func (l *Limiter) Flush() []logentry.Log {
+	now := l.now()
+	var entries []logentry.Log
+	for source, state := range l.sources {
+		if summary, ok := l.summary(source, state, now); ok {
+			entries = append(entries, summary)
+		}
+		// every connection is a new source, the idle ones are not kept forever
+		if state.suppressed == 0 && now.Sub(state.lastSeen) >= l.limit.Window {
+			delete(l.sources, source)
+		}
+	}
+	return entries
+}
+
+func (l *Limiter) keep(state *limitState, now time.Time) bool {
+	if l.limit.Every > 0 {
+		state.seen++
+		return (state.seen-1)%l.limit.Every == 0
+	}
+
+	elapsed := now.Sub(state.refilled).Seconds()
+	state.tokens = min(float64(l.limit.Burst), state.tokens+elapsed*l.limit.Rate)
+	state.refilled = now
+	if state.tokens < 1 {
+		return false
+	}
+	state.tokens--
+	return true
+}
+
+// summary returns the entry reporting the entries suppressed in the window
+// of source, once the window is over.
+func (l *Limiter) summary(source string, state *limitState, now time.Time) (logentry.Log, bool) {
+	if now.Sub(state.windowStart) < l.limit.Window {
+		return logentry.Log{}, false
+	}
+	suppressed := state.suppressed
+	state.suppressed = 0
+	state.windowStart = now
+	if suppressed == 0 {
+		return logentry.Log{}, false
+	}
+	lines := "lines"
+	if suppressed == 1 {
+		lines = "line"
+	}
+
+	return logentry.Log{
+		Line:      fmt.Sprintf("suppressed %s %s in last %s", groupThousands(suppressed), lines, l.limit.Window),
+		Timestamp: now,
+		Source:    source,
+		Level:     "warning",
+		Fields:    map[string]string{"suppressed": strconv.Itoa(suppressed)},
+	}, true
+}
+
+func groupThousands(n int) string {
+	digits := strconv.Itoa(n)
+	var b strings.Builder
+	for i, digit := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(digit)
+	}
+	return b.String()
+}
@@ -0,0 +1,135 @@
+package pipeline_test
+
+import (
+	"regexp"
+	"time"
+
+	"github.com/carlo-colombo/streamlog_go/logentry"
+	"github.com/carlo-colombo/streamlog_go/pipeline"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Limiter", func() {
+	var (
+		now   time.Time
+		clock func() time.Time
+	)
+
+	BeforeEach(func() {
+		now = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		clock = func() time.Time { return now }
+	})
+
+	process := func(p pipeline.Processor, source string, lines ...string) []string {
+		var kept []string
+		for _, line := range lines {
+			for _, entry := range p.Process(logentry.Log{Line: line, Source: source}) {
+				kept = append(kept, entry.Line)
+			}
+		}
+		return kept
+	}
+
+	It("keeps one in every N entries of every source", func() {
+		limiter := pipeline.NewLimiter(pipeline.Limit{Every: 3, Window: 10 * time.Second}, clock)
+
+		Expect(process(limiter, "a", "1", "2", "3", "4", "5")).To(Equal([]string{"1", "4"}))
+		Expect(process(limiter, "b", "1", "2")).To(Equal([]string{"1"}))
+	})
+
+	It("limits only the entries matching the pattern", func() {
+		limiter := pipeline.NewLimiter(pipeline.Limit{
+			Every:  2,
+			Match:  regexp.MustCompile("retry"),
+			Window: 10 * time.Second,
+		}, clock)
+
+		Expect(process(limiter, "a", "retry 1", "done", "retry 2", "retry 3")).
+			To(Equal([]string{"retry 1", "done", "retry 3"}))
+	})
+
+	It("keeps at most rate entries per second in bursts", func() {
+		limiter := pipeline.NewLimiter(pipeline.Limit{Rate: 2, Window: time.Minute}, clock)
+
+		Expect(process(limiter, "a", "1", "2", "3")).To(Equal([]string{"1", "2"}))
+
+		now = now.Add(500 * time.Millisecond)
+		Expect(process(limiter, "a", "4", "5")).To(Equal([]string{"4"}))
+	})
+
+	It("reports the suppressed entries once per window", func() {
+		limiter := pipeline.NewLimiter(pipeline.Limit{Rate: 1, Window: 10 * time.Second}, clock)
+		lines := make([]string, 12346)
+		process(limiter, "a", lines...)
+
+		Expect(limiter.Flush()).To(BeEmpty())
+
+		now = now.Add(10 * time.Second)
+		Expect(limiter.Flush()).To(HaveExactElements(SatisfyAll(
+			HaveField("Line", "suppressed 12,345 lines in last 10s"),
+			HaveField("Source", "a"),
+			HaveField("Timestamp", now),
+			HaveField("Fields", HaveKeyWithValue("suppressed", "12345")),
+		)))
+		Expect(limiter.Flush()).To(BeEmpty())
+	})
+
+	It("reports the suppressed entries before the first entry after the window", func() {
+		limiter := pipeline.NewLimiter(pipeline.Limit{Every: 10, Window: 10 * time.Second}, clock)
+		process(limiter, "a", "1", "2", "3")
+
+		now = now.Add(11 * time.Second)
+		Expect(process(limiter, "a", "4")).To(Equal([]string{"suppressed 2 lines in last 10s"}))
+	})
+
+	It("forgets the sources idle for a whole window", func() {
+		limiter := pipeline.NewLimiter(pipeline.Limit{Every: 2, Window: 10 * time.Second}, clock)
+		process(limiter, "idle", "1")
+		now = now.Add(5 * time.Second)
+		process(limiter, "busy", "1", "2")
+
+		now = now.Add(5 * time.Second)
+		Expect(limiter.Flush()).To(BeEmpty())
+
+		// the idle source starts over, the busy one keeps its suppressed entries
+		Expect(process(limiter, "idle", "2")).To(Equal([]string{"2"}))
+		Expect(process(limiter, "busy", "3", "4")).To(Equal([]string{"3"}))
+
+		now = now.Add(5 * time.Second)
+		Expect(limiter.Flush()).To(HaveExactElements(SatisfyAll(
+			HaveField("Source", "busy"),
+			HaveField("Line", "suppressed 2 lines in last 10s"),
+		)))
+	})
+
+	It("passes the flushed entries through the rest of the chain", func() {
+		limiter := pipeline.NewLimiter(pipeline.Limit{Every: 2, Window: time.Second}, clock)
+		chain := pipeline.Chain{limiter, pipeline.Enrich(map[string]string{"env": "prod"})}
+		chain.Process(logentry.Log{Line: "1"})
+		chain.Process(logentry.Log{Line: "2"})
+
+		now = now.Add(time.Second)
+		Expect(chain.Flush()).To(HaveExactElements(
+			HaveField("Fields", HaveKeyWithValue("env", "prod")),
+		))
+	})
+})
+
+var _ = DescribeTable("ParseLimit",
+	func(kind, spec string, expected pipeline.Limit, expectedErr string) {
+		limit, err := pipeline.ParseLimit(kind, spec, time.Second)
+		if expectedErr != "" {
+			Expect(err).To(MatchError(ContainSubstring(expectedErr)))
+			return
+		}
+		Expect(err).ToNot(HaveOccurred())
+		Expect(limit).To(Equal(expected))
+	},
+	Entry("every", "every", "100", pipeline.Limit{Every: 100, Window: time.Second}, ""),
+	Entry("rate with a pattern", "rate", "0.5:^GET",
+		pipeline.Limit{Rate: 0.5, Match: regexp.MustCompile("^GET"), Window: time.Second}, ""),
+	Entry("a fractional every", "every", "1.5", pipeline.Limit{}, "expected a whole number"),
+	Entry("not a number", "rate", "fast", pipeline.Limit{}, "expected a positive number"),
+	Entry("an invalid pattern", "rate", "1:(", pipeline.Limit{}, "invalid limit pattern"),
+)
@@ -0,0 +1,101 @@
+// Package pipeline composes the steps a log entry goes through between being
+// read from an input and being stored.
+package pipeline
+
+import (
+	"maps"
+	"regexp"
+
+	"github.com/carlo-colombo/streamlog_go/logentry"
+	"github.com/carlo-colombo/streamlog_go/redact"
+)
+
+// Processor transforms a log entry on its way to the store, returning the
+// entries to store in its place: none to drop it, more than one to split it.
+type Processor interface {
+	Process(l logentry.Log) []logentry.Log
+}
+
+// Func adapts a function to a Processor.
+type Func func(l logentry.Log) []logentry.Log
+
+func (f Func) Process(l logentry.Log) []logentry.Log {
+	return f(l)
+}
+
+// Map returns a processor replacing every entry with f(entry).
+func Map(f func(l logentry.Log) logentry.Log) Processor {
+	return Func(func(l logentry.Log) []logentry.Log {
+		return []logentry.Log{f(l)}
+	})
+}
+
+// Chain runs its processors in order, each on the entries returned by the
+// previous one.
+type Chain []Processor
+
+func (c Chain) Process(l logentry.Log) []logentry.Log {
+	entries := []logentry.Log{l}
+	for _, processor := range c {
+		var next []logentry.Log
+		for _, entry := range entries {
+			next = append(next, processor.Process(entry)...)
+		}
+		if len(next) == 0 {
+			return nil
+		}
+		entries = next
+	}
+	return entries
+}
+
+// Decode decodes the line of every entry with decoder, entries split over
+// several lines are returned once complete. The source of the entries is
+// kept, decoder has to be used for a single input.
+func Decode(decoder logentry.Decoder) Processor {
+	return Func(func(l logentry.Log) []logentry.Log {
+		decoded, ok := decoder.Decode(l.Line)
+		if !ok {
+			return nil
+		}
+		decoded.Source = l.Source
+		return []logentry.Log{decoded}
+	})
+}
+
+// Drop drops the entries whose line matches re.
+func Drop(re *regexp.Regexp) Processor {
+	return Func(func(l logentry.Log) []logentry.Log {
+		if re.MatchString(l.Line) {
+			return nil
+		}
+		return []logentry.Log{l}
+	})
+}
+
+// Enrich adds fields to every entry, fields already set by the entry win.
+func Enrich(fields map[string]string) Processor {
+	return Map(func(l logentry.Log) logentry.Log {
+		enriched := make(map[string]string, len(fields)+len(l.Fields))
+		maps.Copy(enriched, fields)
+		maps.Copy(enriched, l.Fields)
+		l.Fields = enriched
+		return l
+	})
+}
+
+// Redact replaces the secrets found by redactor in the line and the fields of
+// every entry.
+func Redact(redactor *redact.Redactor) Processor {
+	return Map(func(l logentry.Log) logentry.Log {
+		l.Line = redactor.Redact(l.Line)
+		if l.Fields != nil {
+			fields := make(map[string]string, len(l.Fields))
+			for key, value := range l.Fields {
+				fields[key] = redactor.Redact(value)
+			}
+			l.Fields = fields
+		}
+		return l
+	})
+}
@@ -0,0 +1,154 @@
+// Package redact replaces secrets in log lines before they are stored.
+package redact
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Rule finds a kind of secret. When Pattern has a group named "secret" only
+// that group is replaced, otherwise the whole match. Validate, when set,
+// discards the matches that are not secrets after all.
+type Rule struct {
+	Name     string
+	Pattern  *regexp.Regexp
+	Validate func(secret string) bool
+}
+
+// Builtin returns the rules for bearer tokens, passwords in URLs and credit
+// card numbers.
+func Builtin() []Rule {
+	return []Rule{
+		{
+			Name:    "bearer",
+			Pattern: regexp.MustCompile(`(?i)\bbearer\s+(?P<secret>[A-Za-z0-9\-._~+/]+=*)`),
+		},
+		{
+			Name:    "url-password",
+			Pattern: regexp.MustCompile(`[A-Za-z][A-Za-z0-9+.\-]*://[^\s:/@]+:(?P<secret>[^\s/@]+)@`),
+		},
+		{
+			Name:     "credit-card",
+			Pattern:  regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`),
+			Validate: luhn,
+		},
+	}
+}
+
+// ruleName is what a rule can be named, so that the name never swallows the
+// start of a pattern.
+var ruleName = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// ParseRule reads a rule from "name=/regexp/". The name, the slashes around
+// the pattern and the pattern are required, a pattern can then contain = and
+// / too.
+func ParseRule(spec string) (Rule, error) {
+	name, pattern, _ := strings.Cut(spec, "=")
+	if !ruleName.MatchString(name) || len(pattern) < 3 ||
+		!strings.HasPrefix(pattern, "/") || !strings.HasSuffix(pattern, "/") {
+		return Rule{}, fmt.Errorf("invalid redaction rule %q, expected name=/regexp/", spec)
+	}
+
+	re, err := regexp.Compile(pattern[1 : len(pattern)-1])
+	if err != nil {
+		return Rule{}, fmt.Errorf("invalid redaction rule %q: %w", spec, err)
+	}
+	return Rule{Name: name, Pattern: re}, nil
+}
+
+// Redactor applies rules, in order, and counts how many secrets each of
+// them replaced.
+type Redactor struct {
+	rules []Rule
+
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func New(rules ...Rule) *Redactor {
+	counts := make(map[string]int, len(rules))
+	for _, rule := range rules {
+		counts[rule.Name] = 0
+	}
+	return &Redactor{rules: rules, counts: counts}
+}
+
+// Redact returns s with the secrets replaced by [REDACTED:<rule name>].
+func (r *Redactor) Redact(s string) string {
+	for _, rule := range r.rules {
+		secret := rule.Pattern.SubexpIndex("secret")
+
+		var redacted int
+		line := s
+		s = replaceAllSubmatchFunc(rule.Pattern, line, func(match []int) string {
+			start, end := match[0], match[1]
+			if secret > 0 && match[2*secret] >= 0 {
+				start, end = match[2*secret], match[2*secret+1]
+			}
+			if rule.Validate != nil && !rule.Validate(line[start:end]) {
+				return line[match[0]:match[1]]
+			}
+
+			redacted++
+			return line[match[0]:start] + "[REDACTED:" + rule.Name + "]" + line[end:match[1]]
+		})
+
+		if redacted > 0 {
+			r.mu.Lock()
+			r.counts[rule.Name] += redacted
+			r.mu.Unlock()
+		}
+	}
+	return s
+}
+
+// Counts returns how many secrets each rule replaced so far.
+func (r *Redactor) Counts() map[string]int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return maps.Clone(r.counts)
+}
+
+// replaceAllSubmatchFunc is regexp.ReplaceAllStringFunc with access to the
+// submatch indexes of every match.
+func replaceAllSubmatchFunc(re *regexp.Regexp, s string, replace func(match []int) string) string {
+	matches := re.FindAllStringSubmatchIndex(s, -1)
+	if matches == nil {
+		return s
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, match := range matches {
+		b.WriteString(s[last:match[0]])
+		b.WriteString(replace(match))
+		last = match[1]
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// luhn reports whether the digits of s have a valid Luhn checksum, as card
+// numbers do.
+func luhn(s string) bool {
+	var sum, digits int
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if digits%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		digits++
+	}
+	return digits >= 13 && sum%10 == 0
+}
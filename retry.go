@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"sync/atomic"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+const (
+	retryAttempts  = 5
+	retryBaseDelay = 10 * time.Millisecond
+	retryMaxDelay  = time.Second
+	// busyTimeout is how long SQLite itself waits for a lock before failing
+	// with SQLITE_BUSY, retry only handles what is left.
+	busyTimeout = 5 * time.Second
+)
+
+// StoreMetrics counts the errors of the database operations.
+type StoreMetrics struct {
+	// Retries counts the attempts repeated after a transient error.
+	Retries int64 `json:"retries"`
+	// TransientErrors counts the operations failed with a transient error
+	// on every attempt.
+	TransientErrors int64 `json:"transient_errors"`
+	// PermanentErrors counts the operations failed with an error that
+	// retrying cannot fix.
+	PermanentErrors int64 `json:"permanent_errors"`
+	// DroppedLogs counts the logs lost to failed writes.
+	DroppedLogs int64 `json:"dropped_logs"`
+}
+
+type storeCounters struct {
+	retries, transient, permanent, dropped atomic.Int64
+}
+
+func (c *storeCounters) metrics() StoreMetrics {
+	return StoreMetrics{
+		Retries:         c.retries.Load(),
+		TransientErrors: c.transient.Load(),
+		PermanentErrors: c.permanent.Load(),
+		DroppedLogs:     c.dropped.Load(),
+	}
+}
+
+// isTransient tells the errors worth retrying, the database being locked by
+// another connection.
+func isTransient(err error) bool {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked
+	}
+	return false
+}
+
+// retry runs operation until it succeeds, fails with a permanent error or ctx
+// is done, waiting a jittered exponential backoff between the attempts.
+func retry(ctx context.Context, counters *storeCounters, operation func(ctx context.Context) error) error {
+	delay := retryBaseDelay
+	for attempt := 1; ; attempt++ {
+		err := operation(ctx)
+		switch {
+		case err == nil:
+			return nil
+		case !isTransient(err):
+			counters.permanent.Add(1)
+			return err
+		case attempt == retryAttempts:
+			counters.transient.Add(1)
+			return err
+		}
+
+		counters.retries.Add(1)
+		timer := time.NewTimer(delay/2 + rand.N(delay/2))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			counters.transient.Add(1)
+			return errors.Join(err, ctx.Err())
+		case <-timer.C:
+		}
+		delay = min(2*delay, retryMaxDelay)
+	}
+}
@@ -0,0 +1,93 @@
+package source
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+)
+
+// Command is a child process whose stdout and stderr are read separately.
+type Command struct {
+	Name   string
+	Stdout io.Reader
+	Stderr io.Reader
+
+	cmd *exec.Cmd
+}
+
+// StartCommand runs args as a child process in a process group of its own.
+// The child inherits stdin, its stdout and stderr must be read until io.EOF
+// before calling Wait.
+func StartCommand(args []string) (*Command, error) {
+	if len(args) == 0 {
+		return nil, errors.New("no command to run")
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = os.Stdin
+	// the terminal signals this process only, they reach the child once
+	// through ForwardSignals
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to capture stdout of %s: %w", args[0], err)
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to capture stderr of %s: %w", args[0], err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %s: %w", args[0], err)
+	}
+
+	return &Command{
+		Name:   filepath.Base(args[0]),
+		Stdout: stdout,
+		Stderr: stderr,
+		cmd:    cmd,
+	}, nil
+}
+
+// ForwardSignals relays sigs received by this process to the process group
+// of the child, like the terminal would, until the returned function is
+// called.
+func (c *Command) ForwardSignals(sigs ...os.Signal) (stop func()) {
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(ch, sigs...)
+
+	go func() {
+		for {
+			select {
+			case sig := <-ch:
+				_ = syscall.Kill(-c.cmd.Process.Pid, sig.(syscall.Signal))
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(ch)
+		close(done)
+	}
+}
+
+// Wait waits for the child to exit and returns its exit code, -1 if it was
+// terminated by a signal.
+func (c *Command) Wait() (int, error) {
+	err := c.cmd.Wait()
+
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return 0, fmt.Errorf("failed to wait for %s: %w", c.Name, err)
+	}
+	return c.cmd.ProcessState.ExitCode(), nil
+}
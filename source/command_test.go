@@ -0,0 +1,67 @@
+package source_test
+
+import (
+	"io"
+	"syscall"
+
+	"github.com/carlo-colombo/streamlog_go/source"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("Source/Command", func() {
+	It("captures stdout and stderr separately and returns the exit code", func() {
+		cmd, err := source.StartCommand([]string{"/bin/sh", "-c", "echo out; echo err >&2; exit 3"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cmd.Name).To(Equal("sh"))
+
+		Expect(io.ReadAll(cmd.Stdout)).To(Equal([]byte("out\n")))
+		Expect(io.ReadAll(cmd.Stderr)).To(Equal([]byte("err\n")))
+
+		Expect(cmd.Wait()).To(Equal(3))
+	})
+
+	It("forwards signals to the child", func() {
+		cmd, err := source.StartCommand([]string{"/bin/sh", "-c", `trap "echo signaled; exit 5" USR2; echo ready; while :; do sleep 0.01; done`})
+		Expect(err).ToNot(HaveOccurred())
+
+		stdout := gbytes.BufferReader(cmd.Stdout)
+		Eventually(stdout).Should(gbytes.Say("ready"))
+
+		stop := cmd.ForwardSignals(syscall.SIGUSR2)
+		defer stop()
+
+		// only reaches the child through the forwarding
+		Expect(syscall.Kill(syscall.Getpid(), syscall.SIGUSR2)).To(Succeed())
+
+		Eventually(stdout).Should(gbytes.Say("signaled"))
+		Expect(cmd.Wait()).To(Equal(5))
+	})
+
+	It("forwards signals to the whole process group of the child", func() {
+		cmd, err := source.StartCommand([]string{"/bin/sh", "-c", `trap "echo child" USR2; (trap "echo grandchild; exit 6" USR2; echo ready; while :; do sleep 0.01; done); exit $?`})
+		Expect(err).ToNot(HaveOccurred())
+
+		stdout := gbytes.BufferReader(cmd.Stdout)
+		Eventually(stdout).Should(gbytes.Say("ready"))
+
+		stop := cmd.ForwardSignals(syscall.SIGUSR2)
+		defer stop()
+
+		Expect(syscall.Kill(syscall.Getpid(), syscall.SIGUSR2)).To(Succeed())
+
+		Eventually(stdout).Should(gbytes.Say("grandchild\nchild"))
+		Expect(cmd.Wait()).To(Equal(6))
+	})
+
+	It("fails when there is no command", func() {
+		_, err := source.StartCommand(nil)
+		Expect(err).To(MatchError("no command to run"))
+	})
+
+	It("fails when the command cannot be started", func() {
+		_, err := source.StartCommand([]string{"/does/not/exist"})
+		Expect(err).To(MatchError(ContainSubstring("failed to start /does/not/exist")))
+	})
+})
@@ -0,0 +1,94 @@
+package source
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+var (
+	gzipMagic  = []byte{0x1f, 0x8b}
+	zstdMagic  = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	bzip2Magic = []byte("BZh")
+)
+
+// Decompress returns a reader over the decompressed content of r when it
+// starts with the magic bytes of gzip, zstd or bzip2, over r as it is
+// otherwise.
+func Decompress(r io.Reader) (io.ReadCloser, error) {
+	buffered := bufio.NewReader(r)
+	// live inputs may write a short line and wait, only the bytes of the
+	// first read are sniffed; shorter inputs are read as they are
+	_, _ = buffered.Peek(1)
+	magic, _ := buffered.Peek(min(buffered.Buffered(), len(zstdMagic)))
+
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		reader, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read gzip header: %w", err)
+		}
+		return reader, nil
+	case bytes.HasPrefix(magic, zstdMagic):
+		decoder, err := zstd.NewReader(buffered, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read zstd header: %w", err)
+		}
+		return decoder.IOReadCloser(), nil
+	case bytes.HasPrefix(magic, bzip2Magic):
+		return io.NopCloser(bzip2.NewReader(buffered)), nil
+	}
+	return io.NopCloser(buffered), nil
+}
+
+// Open returns a reader for the file at path. Compressed files are archives,
+// they are decompressed and read once, other files are followed (see Follow).
+func Open(path string, interval time.Duration) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		// not created yet, or will fail again while following it
+		return Follow(path, interval), nil
+	}
+
+	magic := make([]byte, len(zstdMagic))
+	n, _ := io.ReadFull(file, magic)
+	if !isCompressed(magic[:n]) {
+		_ = file.Close()
+		return Follow(path, interval), nil
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("failed to rewind %s: %w", path, err)
+	}
+	reader, err := Decompress(file)
+	if err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("failed to decompress %s: %w", path, err)
+	}
+	return &archive{ReadCloser: reader, file: file}, nil
+}
+
+func isCompressed(magic []byte) bool {
+	return bytes.HasPrefix(magic, gzipMagic) ||
+		bytes.HasPrefix(magic, zstdMagic) ||
+		bytes.HasPrefix(magic, bzip2Magic)
+}
+
+// archive closes both the decompressor and the underlying file.
+type archive struct {
+	io.ReadCloser
+	file *os.File
+}
+
+func (a *archive) Close() error {
+	return errors.Join(a.ReadCloser.Close(), a.file.Close())
+}
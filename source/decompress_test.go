@@ -0,0 +1,123 @@
+package source_test
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/carlo-colombo/streamlog_go/source"
+	"github.com/klauspost/compress/zstd"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Source/Decompress", func() {
+	const content = "first\nsecond\n"
+
+	gzipped := func() []byte {
+		var buffer bytes.Buffer
+		writer := gzip.NewWriter(&buffer)
+		_, _ = writer.Write([]byte(content))
+		Expect(writer.Close()).To(Succeed())
+		return buffer.Bytes()
+	}
+
+	zstded := func() []byte {
+		encoder, err := zstd.NewWriter(nil)
+		Expect(err).ToNot(HaveOccurred())
+		return encoder.EncodeAll([]byte(content), nil)
+	}
+
+	// printf 'first\nsecond\n' | bzip2
+	bzipped := []byte{
+		0x42, 0x5a, 0x68, 0x39, 0x31, 0x41, 0x59, 0x26, 0x53, 0x59, 0x67, 0x62, 0xd4, 0x8d, 0x00, 0x00,
+		0x02, 0xc1, 0x80, 0x00, 0x10, 0x0f, 0x21, 0x9c, 0x00, 0x20, 0x00, 0x22, 0x00, 0x69, 0x90, 0x80,
+		0x69, 0xa6, 0x89, 0x56, 0x16, 0x03, 0xc6, 0xd6, 0xf8, 0xbb, 0x92, 0x29, 0xc2, 0x84, 0x83, 0x3b,
+		0x16, 0xa4, 0x68,
+	}
+
+	DescribeTable("decompresses by magic bytes",
+		func(compressed func() []byte) {
+			reader, err := source.Decompress(bytes.NewReader(compressed()))
+			Expect(err).ToNot(HaveOccurred())
+			defer reader.Close()
+
+			Expect(io.ReadAll(reader)).To(Equal([]byte(content)))
+		},
+		Entry("gzip", gzipped),
+		Entry("zstd", zstded),
+		Entry("bzip2", func() []byte { return bzipped }),
+	)
+
+	It("reads other content as it is", func() {
+		for _, plain := range []string{content, "a", ""} {
+			reader, err := source.Decompress(bytes.NewReader([]byte(plain)))
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(io.ReadAll(reader)).To(Equal([]byte(plain)))
+		}
+	})
+
+	It("does not wait for the magic bytes on inputs still open", func() {
+		r, w := io.Pipe()
+		DeferCleanup(w.Close)
+		go func() { _, _ = w.Write([]byte("a\n")) }()
+
+		read := make(chan string)
+		go func() {
+			defer GinkgoRecover()
+			reader, err := source.Decompress(r)
+			Expect(err).ToNot(HaveOccurred())
+			line, _ := bufio.NewReader(reader).ReadString('\n')
+			read <- line
+		}()
+
+		Eventually(read).Should(Receive(Equal("a\n")))
+	})
+
+	It("fails on corrupted headers", func() {
+		_, err := source.Decompress(bytes.NewReader([]byte{0x1f, 0x8b, 0x00, 0x00}))
+		Expect(err).To(MatchError(ContainSubstring("failed to read gzip header")))
+	})
+
+	Describe("Open", func() {
+		var dir string
+
+		BeforeEach(func() {
+			dir = GinkgoT().TempDir()
+		})
+
+		It("reads compressed files once", func() {
+			path := filepath.Join(dir, "app.log.gz")
+			Expect(os.WriteFile(path, gzipped(), 0o644)).To(Succeed())
+
+			reader, err := source.Open(path, 10*time.Millisecond)
+			Expect(err).ToNot(HaveOccurred())
+			defer reader.Close()
+
+			Expect(io.ReadAll(reader)).To(Equal([]byte(content)))
+		})
+
+		It("follows other files", func() {
+			path := filepath.Join(dir, "app.log")
+			Expect(os.WriteFile(path, []byte(content), 0o644)).To(Succeed())
+
+			reader, err := source.Open(path, 10*time.Millisecond)
+			Expect(err).ToNot(HaveOccurred())
+			defer reader.Close()
+
+			Expect(reader).To(BeAssignableToTypeOf(&source.File{}))
+		})
+
+		It("follows files that do not exist yet", func() {
+			reader, err := source.Open(filepath.Join(dir, "missing.log"), 10*time.Millisecond)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(reader).To(BeAssignableToTypeOf(&source.File{}))
+		})
+	})
+})
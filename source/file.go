@@ -0,0 +1,133 @@
+package source
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// File is a reader that follows a file like `tail -F`: once the end of the
+// file is reached it waits for new data instead of returning io.EOF. It
+// starts over when the file is truncated and reopens the path when the file
+// is rotated (renamed or removed and recreated).
+type File struct {
+	path     string
+	interval time.Duration
+
+	file   *os.File
+	info   os.FileInfo
+	offset int64
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// Follow returns a reader over the content of path, polling for changes every
+// interval. The file does not need to exist yet.
+func Follow(path string, interval time.Duration) *File {
+	return &File{
+		path:     path,
+		interval: interval,
+		closed:   make(chan struct{}),
+	}
+}
+
+// Read blocks until there is data to read. It only returns io.EOF after Close.
+func (f *File) Read(p []byte) (int, error) {
+	for {
+		if f.file == nil {
+			if err := f.open(); err != nil {
+				return 0, err
+			}
+		}
+
+		if f.file != nil {
+			n, err := f.file.Read(p)
+			f.offset += int64(n)
+			if n > 0 {
+				return n, nil
+			}
+			if err != nil && !errors.Is(err, io.EOF) {
+				return 0, fmt.Errorf("failed to read %s: %w", f.path, err)
+			}
+
+			if err := f.checkRotation(); err != nil {
+				return 0, err
+			}
+			if f.file == nil {
+				// reopened, read the new file straight away
+				continue
+			}
+		}
+
+		select {
+		case <-f.closed:
+			if f.file != nil {
+				_ = f.file.Close()
+			}
+			return 0, io.EOF
+		case <-time.After(f.interval):
+		}
+	}
+}
+
+// open opens the path, leaving f.file nil if it does not exist (yet).
+func (f *File) open() error {
+	file, err := os.Open(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", f.path, err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to stat %s: %w", f.path, err)
+	}
+
+	f.file = file
+	f.info = info
+	f.offset = 0
+	return nil
+}
+
+// checkRotation is called when the end of the current file is reached. It
+// rewinds the file if it has been truncated, and drops it if the path now
+// points to a different file so that the next read reopens it.
+func (f *File) checkRotation() error {
+	info, err := os.Stat(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		// removed or renamed, keep the old file until a new one shows up
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", f.path, err)
+	}
+
+	if !os.SameFile(f.info, info) {
+		// anything written to the old file before the rotation has already
+		// been read since Read got to its end
+		_ = f.file.Close()
+		f.file = nil
+		return nil
+	}
+
+	if info.Size() < f.offset {
+		if _, err := f.file.Seek(0, io.SeekStart); err != nil {
+			return fmt.Errorf("failed to rewind truncated %s: %w", f.path, err)
+		}
+		f.offset = 0
+	}
+	return nil
+}
+
+// Close stops following the file, pending and future reads return io.EOF.
+func (f *File) Close() error {
+	f.closeOnce.Do(func() { close(f.closed) })
+	return nil
+}
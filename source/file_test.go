@@ -0,0 +1,95 @@
+package source_test
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/carlo-colombo/streamlog_go/source"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Source/File", func() {
+	var path string
+	var file *source.File
+	var lines chan string
+
+	appendTo := func(path string, content string) {
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = f.WriteString(content)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(f.Close()).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		path = filepath.Join(GinkgoT().TempDir(), "app.log")
+	})
+
+	JustBeforeEach(func() {
+		file = source.Follow(path, 10*time.Millisecond)
+		lines = make(chan string, 10)
+
+		go func(file *source.File, lines chan string) {
+			defer GinkgoRecover()
+			scanner := bufio.NewScanner(file)
+			for scanner.Scan() {
+				lines <- scanner.Text()
+			}
+			close(lines)
+		}(file, lines)
+
+		DeferCleanup(file.Close)
+	})
+
+	Context("when the file already has content", func() {
+		BeforeEach(func() {
+			appendTo(path, "first\nsecond\n")
+		})
+
+		It("reads it from the start and follows what is appended", func() {
+			Eventually(lines).Should(Receive(Equal("first")))
+			Eventually(lines).Should(Receive(Equal("second")))
+
+			appendTo(path, "third\n")
+			Eventually(lines).Should(Receive(Equal("third")))
+		})
+
+		It("starts over when the file is truncated", func() {
+			Eventually(lines).Should(Receive(Equal("first")))
+			Eventually(lines).Should(Receive(Equal("second")))
+
+			Expect(os.WriteFile(path, []byte("new\n"), 0o644)).To(Succeed())
+			Eventually(lines).Should(Receive(Equal("new")))
+		})
+
+		It("reopens the path when the file is rotated", func() {
+			Eventually(lines).Should(Receive(Equal("first")))
+			Eventually(lines).Should(Receive(Equal("second")))
+
+			Expect(os.Rename(path, path+".1")).To(Succeed())
+			appendTo(path+".1", "late write to the rotated file\n")
+			Eventually(lines).Should(Receive(Equal("late write to the rotated file")))
+
+			appendTo(path, "fresh file\n")
+			Eventually(lines).Should(Receive(Equal("fresh file")))
+		})
+	})
+
+	It("waits for the file to be created", func() {
+		Consistently(lines, "50ms").ShouldNot(Receive())
+
+		appendTo(path, "created\n")
+		Eventually(lines).Should(Receive(Equal("created")))
+	})
+
+	It("stops at the end of the file once closed", func() {
+		appendTo(path, "only line\n")
+		Eventually(lines).Should(Receive(Equal("only line")))
+
+		Expect(file.Close()).To(Succeed())
+		Eventually(lines).Should(BeClosed())
+	})
+})
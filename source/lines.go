@@ -0,0 +1,28 @@
+package source
+
+import (
+	"bufio"
+	"io"
+)
+
+// MaxLineLength is the longest line read from an input. Longer lines, like a
+// run of binary data without newlines, are split in lines of this length
+// instead of ending the input.
+const MaxLineLength = 1 << 20
+
+// NewLineScanner returns a scanner of the lines of r, like bufio.ScanLines
+// but splitting the lines longer than MaxLineLength.
+func NewLineScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 4096), MaxLineLength)
+	scanner.Split(scanLines)
+	return scanner
+}
+
+func scanLines(data []byte, atEOF bool) (int, []byte, error) {
+	advance, token, err := bufio.ScanLines(data, atEOF)
+	if advance == 0 && err == nil && len(data) >= MaxLineLength {
+		return MaxLineLength, data[:MaxLineLength], nil
+	}
+	return advance, token, err
+}
@@ -0,0 +1,43 @@
+package source_test
+
+import (
+	"strings"
+
+	"github.com/carlo-colombo/streamlog_go/source"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Source/Lines", func() {
+	scan := func(input string) []string {
+		scanner := source.NewLineScanner(strings.NewReader(input))
+		var lines []string
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		Expect(scanner.Err()).ToNot(HaveOccurred())
+		return lines
+	}
+
+	It("reads lines like bufio.ScanLines", func() {
+		Expect(scan("first\r\nsecond\nlast")).To(Equal([]string{"first", "second", "last"}))
+	})
+
+	It("reads lines longer than the default limit of bufio.Scanner", func() {
+		long := strings.Repeat("x", 100*1024)
+
+		Expect(scan(long + "\nnext\n")).To(Equal([]string{long, "next"}))
+	})
+
+	It("splits the lines longer than MaxLineLength", func() {
+		long := strings.Repeat("x", 2*source.MaxLineLength+10)
+
+		lines := scan(long + "\nnext\n")
+
+		Expect(lines).To(HaveLen(4))
+		Expect(lines[0]).To(HaveLen(source.MaxLineLength))
+		Expect(lines[1]).To(HaveLen(source.MaxLineLength))
+		Expect(lines[2]).To(Equal("xxxxxxxxxx"))
+		Expect(lines[3]).To(Equal("next"))
+	})
+})
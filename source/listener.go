@@ -0,0 +1,96 @@
+package source
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net"
+	"os"
+	"sync/atomic"
+	"syscall"
+)
+
+// Listener accepts connections from clients writing newline-delimited logs,
+// e.g. `nc localhost 9000 < app.log`.
+type Listener struct {
+	network  string
+	listener net.Listener
+	accepted atomic.Int64
+}
+
+// Listen binds addr on network, "tcp" or "unix". A socket file left behind
+// at a unix addr is replaced, unless it still accepts connections.
+func Listen(network, addr string) (*Listener, error) {
+	if network == "unix" {
+		if err := removeStaleSocket(addr); err != nil {
+			return nil, fmt.Errorf("failed to listen on %s %s: %w", network, addr, err)
+		}
+	}
+
+	listener, err := net.Listen(network, addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s %s: %w", network, addr, err)
+	}
+
+	return &Listener{network: network, listener: listener}, nil
+}
+
+// removeStaleSocket removes the socket file at addr when nothing listens on
+// it anymore, which refuses the connections.
+func removeStaleSocket(addr string) error {
+	info, err := os.Stat(addr)
+	if err != nil || info.Mode().Type() != fs.ModeSocket {
+		return nil
+	}
+
+	conn, err := net.Dial("unix", addr)
+	if err == nil {
+		_ = conn.Close()
+		return syscall.EADDRINUSE
+	}
+	if !errors.Is(err, syscall.ECONNREFUSED) {
+		return fmt.Errorf("%w: %w", syscall.EADDRINUSE, err)
+	}
+	return os.Remove(addr)
+}
+
+func (l *Listener) Addr() net.Addr {
+	return l.listener.Addr()
+}
+
+// Serve calls scan for every connection, concurrently, with the name of the
+// connection as source, until the listener is closed.
+func (l *Listener) Serve(scan func(r io.Reader, source string)) {
+	for {
+		conn, err := l.listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+		if err != nil {
+			log.Printf("Failed to accept connection on %s: %v", l.listener.Addr(), err)
+			continue
+		}
+
+		source := l.sourceFor(conn)
+		go func() {
+			defer conn.Close()
+			scan(conn, source)
+		}()
+	}
+}
+
+// sourceFor names a connection after its peer. Peers of unix sockets are
+// unnamed, they are numbered instead.
+func (l *Listener) sourceFor(conn net.Conn) string {
+	n := l.accepted.Add(1)
+	if l.network == "unix" {
+		return fmt.Sprintf("unix:%s#%d", l.listener.Addr(), n)
+	}
+	return fmt.Sprintf("%s:%s", l.network, conn.RemoteAddr())
+}
+
+func (l *Listener) Close() error {
+	return l.listener.Close()
+}
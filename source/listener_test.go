@@ -0,0 +1,106 @@
+package source_test
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"syscall"
+
+	"github.com/carlo-colombo/streamlog_go/source"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Source/Listener", func() {
+	type line struct {
+		Text   string
+		Source string
+	}
+
+	var lines chan line
+
+	serve := func(network, addr string) *source.Listener {
+		listener, err := source.Listen(network, addr)
+		Expect(err).ToNot(HaveOccurred())
+		DeferCleanup(listener.Close)
+
+		go listener.Serve(func(r io.Reader, source string) {
+			scanner := bufio.NewScanner(r)
+			for scanner.Scan() {
+				lines <- line{Text: scanner.Text(), Source: source}
+			}
+		})
+		return listener
+	}
+
+	BeforeEach(func() {
+		lines = make(chan line, 10)
+	})
+
+	It("reads lines from TCP connections, each as its own source", func() {
+		listener := serve("tcp", "127.0.0.1:0")
+
+		first, err := net.Dial("tcp", listener.Addr().String())
+		Expect(err).ToNot(HaveOccurred())
+		defer first.Close()
+		second, err := net.Dial("tcp", listener.Addr().String())
+		Expect(err).ToNot(HaveOccurred())
+		defer second.Close()
+
+		_, _ = fmt.Fprintln(first, "from the first")
+		Eventually(lines).Should(Receive(Equal(line{"from the first", "tcp:" + first.LocalAddr().String()})))
+
+		_, _ = fmt.Fprintln(second, "from the second")
+		Eventually(lines).Should(Receive(Equal(line{"from the second", "tcp:" + second.LocalAddr().String()})))
+	})
+
+	It("reads lines from unix socket connections", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "streamlog.sock")
+		serve("unix", path)
+
+		for _, text := range []string{"first", "second"} {
+			conn, err := net.Dial("unix", path)
+			Expect(err).ToNot(HaveOccurred())
+			_, _ = fmt.Fprintln(conn, text)
+			Expect(conn.Close()).To(Succeed())
+		}
+
+		var received []line
+		Eventually(func() []line {
+			select {
+			case l := <-lines:
+				received = append(received, l)
+			default:
+			}
+			return received
+		}).Should(ConsistOf(
+			line{"first", "unix:" + path + "#1"},
+			line{"second", "unix:" + path + "#2"},
+		))
+	})
+
+	It("replaces a socket file left behind", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "streamlog.sock")
+
+		stale, err := net.Listen("unix", path)
+		Expect(err).ToNot(HaveOccurred())
+		stale.(*net.UnixListener).SetUnlinkOnClose(false)
+		Expect(stale.Close()).To(Succeed())
+
+		serve("unix", path)
+	})
+
+	It("does not take over a socket still accepting connections", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "streamlog.sock")
+		serve("unix", path)
+
+		_, err := source.Listen("unix", path)
+		Expect(err).To(MatchError(syscall.EADDRINUSE))
+
+		conn, err := net.Dial("unix", path)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(conn.Close()).To(Succeed())
+	})
+})
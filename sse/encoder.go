@@ -21,8 +21,14 @@ func NewEncoder(w io.Writer) Encoder {
 }
 
 type rawMessage struct {
-	Line      string    `json:"line"`
-	Timestamp time.Time `json:"timestamp"`
+	Line          string            `json:"line"`
+	Timestamp     time.Time         `json:"timestamp"`
+	Source        string            `json:"source,omitempty"`
+	Level         string            `json:"level,omitempty"`
+	Fields        map[string]string `json:"fields,omitempty"`
+	ID            int64             `json:"id,omitempty"`
+	Repeat        int               `json:"repeat,omitempty"`
+	LastTimestamp time.Time         `json:"last_timestamp,omitzero"`
 }
 
 func (e Encoder) Encode(v any) error {
@@ -33,8 +39,14 @@ func (e Encoder) Encode(v any) error {
 
 	// Use a custom type to avoid escaping in the Line field
 	raw := rawMessage{
-		Line:      l.Line,
-		Timestamp: l.Timestamp,
+		Line:          l.Line,
+		Timestamp:     l.Timestamp,
+		Source:        l.Source,
+		Level:         l.Level,
+		Fields:        l.Fields,
+		ID:            l.ID,
+		Repeat:        l.Repeat,
+		LastTimestamp: l.LastTimestamp,
 	}
 
 	// Use json.Marshal with HTMLEscape disabled
@@ -52,3 +64,15 @@ func (e Encoder) Encode(v any) error {
 	fmt.Fprintf(e.writer, "data: %s\n\n", data)
 	return nil
 }
+
+// EncodeEvent writes v as the JSON payload of a named event, so that clients
+// can tell it apart from the log lines sent as unnamed messages.
+func (e Encoder) EncodeEvent(event string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s event: %w", event, err)
+	}
+
+	fmt.Fprintf(e.writer, "event: %s\ndata: %s\n\n", event, data)
+	return nil
+}
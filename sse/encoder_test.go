@@ -22,6 +22,26 @@ var _ = Describe("Sse/Encoder", func() {
 		Eventually(buffer).Should(gbytes.Say("data: {\"line\":\"foobar\",\"timestamp\":\"0001-01-01T00:00:00Z\"}\n\n"))
 	})
 
+	It("includes the source of the log", func() {
+		buffer := gbytes.NewBuffer()
+		e := sse.NewEncoder(buffer)
+
+		err := e.Encode(logentry.Log{Line: "foobar", Source: "app.log"})
+		Expect(err).ToNot(HaveOccurred())
+
+		Eventually(buffer).Should(gbytes.Say("data: {\"line\":\"foobar\",\"timestamp\":\"0001-01-01T00:00:00Z\",\"source\":\"app.log\"}\n\n"))
+	})
+
+	It("includes the level and structured fields of the log", func() {
+		buffer := gbytes.NewBuffer()
+		e := sse.NewEncoder(buffer)
+
+		err := e.Encode(logentry.Log{Line: "foobar", Level: "error", Fields: map[string]string{"hostname": "router"}})
+		Expect(err).ToNot(HaveOccurred())
+
+		Eventually(buffer).Should(gbytes.Say(`"level":"error","fields":{"hostname":"router"}}`))
+	})
+
 	It("returns an error if is not a log", func() {
 		e := sse.NewEncoder(gbytes.NewBuffer())
 
@@ -30,4 +50,21 @@ var _ = Describe("Sse/Encoder", func() {
 		err := e.Encode(nil)
 		Expect(err).To(MatchError(ContainSubstring("encoder can only encode a log object")))
 	})
+
+	It("encodes a named event", func() {
+		buffer := gbytes.NewBuffer()
+		e := sse.NewEncoder(buffer)
+
+		err := e.EncodeEvent("status", map[string]string{"state": "eof"})
+		Expect(err).ToNot(HaveOccurred())
+
+		Eventually(buffer).Should(gbytes.Say("event: status\ndata: {\"state\":\"eof\"}\n\n"))
+	})
+
+	It("returns an error if the event payload cannot be encoded", func() {
+		e := sse.NewEncoder(gbytes.NewBuffer())
+
+		err := e.EncodeEvent("status", make(chan int))
+		Expect(err).To(MatchError(ContainSubstring("failed to marshal status event")))
+	})
 })
@@ -0,0 +1,23 @@
+package main
+
+import "time"
+
+// IngestionState describes whether the store is still reading its input.
+type IngestionState string
+
+const (
+	IngestionRunning IngestionState = "running"
+	IngestionEOF     IngestionState = "eof"
+	IngestionError   IngestionState = "error"
+)
+
+type IngestionStatus struct {
+	State     IngestionState `json:"state"`
+	Error     string         `json:"error,omitempty"`
+	UpdatedAt time.Time      `json:"updatedAt"`
+}
+
+// Ended reports whether no more lines will be read from the input.
+func (s IngestionStatus) Ended() bool {
+	return s.State == IngestionEOF || s.State == IngestionError
+}
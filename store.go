@@ -2,153 +2,901 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	stdlog "log"
 	"maps"
+	"os"
+	"path/filepath"
 	"slices"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/carlo-colombo/streamlog_go/export"
 	"github.com/carlo-colombo/streamlog_go/logentry"
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/carlo-colombo/streamlog_go/migrations"
+	"github.com/carlo-colombo/streamlog_go/pipeline"
+	"github.com/carlo-colombo/streamlog_go/source"
+	"github.com/mattn/go-sqlite3"
 )
 
 type SQLiteLogsStore struct {
-	db             *sql.DB
-	clients        map[string]chan logentry.Log
-	filter         string
-	filterChangeCh chan struct{}
+	db           *sql.DB
+	ctx          context.Context
+	cancel       context.CancelFunc
+	counters     *storeCounters
+	clientsMu    sync.Mutex
+	clients      map[string]chan logentry.Log
+	tee          *Tee
+	newDecoder   func() logentry.Decoder
+	binaryPolicy logentry.BinaryPolicy
+	pipeline     pipeline.Chain
+	collapse     logentry.CollapseMode
+	lastBySource map[string]collapsedLog
+	ingestMu     sync.Mutex
+	snapshotDir  string
+
+	// the filters change while the logs are read and broadcast
+	filterMu sync.RWMutex
+	filter   logFilter
+
+	// imported logs skip the pipeline, only going through this one
+	importPipeline pipeline.Chain
+
+	// logs are split in partitions when set, db being the current one
+	partitions *partitions
+	now        func() time.Time
+	archivers  sync.WaitGroup
+
+	// logs are written in batches, flushed once full or after the interval
+	writeMu       sync.Mutex
+	pending       []pendingWrite
+	unsent        []logentry.Log
+	flushTimer    *time.Timer
+	batchSize     int
+	batchInterval time.Duration
+	broadcastMu   sync.Mutex
+
+	statusMu      sync.Mutex
+	status        IngestionStatus
+	scanning      int
+	statusClients map[string]chan IngestionStatus
+
+	// clients are told to reload the logs on these, e.g. on filter changes
+	resetMu      sync.Mutex
+	resetClients map[string]chan struct{}
 }
 
-func retryWithBackoff(operation func() error, maxRetries int) error {
-	var err error
-	for i := 0; i < maxRetries; i++ {
-		err = operation()
-		if err == nil {
-			return nil
-		}
-		backoff := time.Duration(1<<uint(i)) * time.Second
-		stdlog.Printf("Operation failed, retrying in %v: %v", backoff, err)
-		time.Sleep(backoff)
+const (
+	DefaultBatchSize     = 500
+	DefaultBatchInterval = 50 * time.Millisecond
+)
+
+// clientBacklog is how many logs wait for a client before it is told to
+// reload them all instead.
+const clientBacklog = 4096
+
+func NewSQLiteStore(dbPath string) (*SQLiteLogsStore, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	counters := &storeCounters{}
+
+	db, err := openDatabase(ctx, counters, dbPath)
+	if err != nil {
+		cancel()
+		return nil, err
 	}
-	return fmt.Errorf("operation failed after %d retries: %w", maxRetries, err)
+
+	return newSQLiteStore(ctx, cancel, db, counters), nil
 }
 
-func NewSQLiteStore(dbPath string) (*SQLiteLogsStore, error) {
-	var db *sql.DB
-	err := retryWithBackoff(func() error {
-		var err error
-		db, err = sql.Open("sqlite3", dbPath)
-		if err != nil {
-			return fmt.Errorf("failed to open database: %w", err)
-		}
-		return nil
-	}, 3)
+// NewPartitionedSQLiteStore splits the logs in a database file per period,
+// named after dbPath, rolling over to a new one as time goes by. The
+// partitions that ended long enough ago are archived and removed, as set by
+// partitioning. now tells the time of the partitions.
+func NewPartitionedSQLiteStore(dbPath string, partitioning Partitioning, now func() time.Time) (*SQLiteLogsStore, error) {
+	if partitioning.Period == PartitionOff {
+		return NewSQLiteStore(dbPath)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	counters := &storeCounters{}
+
+	partitions := newPartitions(dbPath, partitioning)
+	if err := partitions.migrate(ctx, counters); err != nil {
+		cancel()
+		return nil, err
+	}
 
+	partitions.current = partitioning.Period.start(now())
+	db, err := partitions.open(ctx, counters, partitions.current)
 	if err != nil {
+		cancel()
 		return nil, err
 	}
+	partitions.dropExpired(now())
+	partitions.archiveCold(ctx, counters, now())
+
+	store := newSQLiteStore(ctx, cancel, db, counters)
+	store.partitions = partitions
+	store.now = now
+	return store, nil
+}
+
+// openDatabase opens the database at dbPath for writing, upgrading its schema
+// to the latest one.
+func openDatabase(ctx context.Context, counters *storeCounters, dbPath string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite3", withPragmas(dbPath))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
 
 	// Configure connection pool
 	db.SetMaxOpenConns(1) // SQLite only supports one writer at a time
 	db.SetMaxIdleConns(1)
 	db.SetConnMaxLifetime(time.Hour)
 
-	// Create logs table if it doesn't exist with transaction
-	err = retryWithBackoff(func() error {
-		tx, err := db.Begin()
-		if err != nil {
-			return fmt.Errorf("failed to begin transaction: %w", err)
-		}
-		defer tx.Rollback()
-
-		// Create table if it doesn't exist
-		_, err = tx.Exec(`
-			CREATE TABLE IF NOT EXISTS logs (
-				id INTEGER PRIMARY KEY AUTOINCREMENT,
-				line TEXT NOT NULL,
-				timestamp DATETIME NOT NULL
-			)
-		`)
+	err = retry(ctx, counters, func(ctx context.Context) error {
+		return migrations.Migrate(ctx, db)
+	})
+
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
+	}
+	return db, nil
+}
+
+// NewReadOnlySQLiteStore opens the database at dbPath to browse the logs
+// already stored. The database is not migrated, it has to have the latest
+// schema.
+func NewReadOnlySQLiteStore(dbPath string) (*SQLiteLogsStore, error) {
+	db, err := sql.Open("sqlite3", readOnlyDSN(dbPath))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	counters := &storeCounters{}
+
+	var version int
+	err = retry(ctx, counters, func(ctx context.Context) error {
+		var err error
+		version, err = migrations.Version(ctx, db)
+		return err
+	})
+
+	switch latest := migrations.Latest(); {
+	case err != nil:
+		err = fmt.Errorf("failed to read database %s: %w", dbPath, err)
+	case version == 0:
+		err = fmt.Errorf("%s is not a streamlog database", dbPath)
+	case version < latest:
+		err = fmt.Errorf("database schema version %d is older than %d, open %s once without --readonly to upgrade it", version, latest, dbPath)
+	case version > latest:
+		err = fmt.Errorf("unknown database schema version %d, the latest supported is %d", version, latest)
+	}
+	if err != nil {
+		cancel()
+		db.Close()
+		return nil, err
+	}
+
+	store := newSQLiteStore(ctx, cancel, db, counters)
+	store.status = IngestionStatus{State: IngestionEOF, UpdatedAt: time.Now()}
+	return store, nil
+}
+
+func newSQLiteStore(ctx context.Context, cancel context.CancelFunc, db *sql.DB, counters *storeCounters) *SQLiteLogsStore {
+	return &SQLiteLogsStore{
+		db:            db,
+		ctx:           ctx,
+		cancel:        cancel,
+		counters:      counters,
+		clients:       make(map[string]chan logentry.Log),
+		newDecoder:    func() logentry.Decoder { return logentry.RawDecoder{} },
+		binaryPolicy:  logentry.BinaryReplace,
+		collapse:      logentry.CollapseOff,
+		lastBySource:  make(map[string]collapsedLog),
+		batchSize:     DefaultBatchSize,
+		batchInterval: DefaultBatchInterval,
+		status:        IngestionStatus{State: IngestionRunning, UpdatedAt: time.Now()},
+		statusClients: make(map[string]chan IngestionStatus),
+		resetClients:  make(map[string]chan struct{}),
+		now:           time.Now,
+	}
+}
+
+// readOnlyDSN opens the database at dbPath read-only, waiting up to
+// busyTimeout for the locks held by other connections.
+func readOnlyDSN(dbPath string) string {
+	return fmt.Sprintf("file:%s?mode=ro&_busy_timeout=%d", dbPath, busyTimeout.Milliseconds())
+}
+
+// withPragmas enables WAL mode, so that reading the logs does not block
+// writing them, relaxing the syncs to the ones WAL needs to stay consistent.
+// SQLite waits up to busyTimeout for the locks held by other connections.
+func withPragmas(dbPath string) string {
+	separator := "?"
+	if strings.Contains(dbPath, "?") {
+		separator = "&"
+	}
+	return fmt.Sprintf("%s%s_journal_mode=WAL&_synchronous=NORMAL&_busy_timeout=%d",
+		dbPath, separator, busyTimeout.Milliseconds())
+}
+
+func (s *SQLiteLogsStore) SetFilter(filter string) {
+	s.filterMu.Lock()
+	s.filter.line = filter
+	s.filterMu.Unlock()
+	s.resetAll()
+}
+
+// SetSourceFilter restricts the logs to the ones coming from source, an
+// empty source matches all of them.
+func (s *SQLiteLogsStore) SetSourceFilter(source string) {
+	s.filterMu.Lock()
+	s.filter.source = source
+	s.filterMu.Unlock()
+	s.resetAll()
+}
+
+// Sources returns the distinct sources of the stored logs.
+func (s *SQLiteLogsStore) Sources() []string {
+	s.writePending()
+
+	sources := make(map[string]bool)
+	err := s.eachPartition(func(db *sql.DB) error {
+		return retry(s.ctx, s.counters, func(ctx context.Context) error {
+			rows, err := db.QueryContext(ctx, "SELECT DISTINCT source FROM logs WHERE source != ''")
+			if err != nil {
+				return fmt.Errorf("failed to query sources: %w", err)
+			}
+			defer rows.Close()
+
+			for rows.Next() {
+				var source string
+				if err := rows.Scan(&source); err != nil {
+					return fmt.Errorf("failed to scan source: %w", err)
+				}
+				sources[source] = true
+			}
+			return rows.Err()
+		})
+	}, func(logLine logentry.Log) error {
+		if logLine.Source != "" {
+			sources[logLine.Source] = true
+		}
+		return nil
+	})
+
+	if err != nil {
+		stdlog.Printf("Failed to list sources: %v", err)
+		return nil
+	}
+
+	return slices.Sorted(maps.Keys(sources))
+}
+
+// SetTee copies every ingested line to tee before it is stored and broadcast.
+func (s *SQLiteLogsStore) SetTee(tee *Tee) {
+	s.tee = tee
+}
+
+// SetDecoder sets how the lines read by Scan and ScanSource are decoded,
+// newDecoder is called once for every input.
+func (s *SQLiteLogsStore) SetDecoder(newDecoder func() logentry.Decoder) {
+	s.newDecoder = newDecoder
+}
+
+// SetBinaryPolicy sets how invalid UTF-8 and control characters are made
+// safe before storing the logs.
+func (s *SQLiteLogsStore) SetBinaryPolicy(policy logentry.BinaryPolicy) {
+	s.binaryPolicy = policy
+}
+
+// SetPipeline sets the processors every ingested entry goes through, in
+// order, before being stored and broadcast.
+func (s *SQLiteLogsStore) SetPipeline(processors ...pipeline.Processor) {
+	s.pipeline = processors
+}
+
+// SetImportPipeline sets the processors the imported logs go through, which
+// skip the pipeline of the ingested ones. They are meant to rewrite the logs,
+// like the redaction, rather than to drop them.
+func (s *SQLiteLogsStore) SetImportPipeline(processors ...pipeline.Processor) {
+	s.importPipeline = processors
+}
+
+// SetBatch sets how many logs are written in a single transaction, and how
+// long a log can wait for its batch to fill before it is written anyway.
+func (s *SQLiteLogsStore) SetBatch(size int, interval time.Duration) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.batchSize = max(size, 1)
+	s.batchInterval = interval
+}
+
+// SetCollapse sets how consecutive repeats of a log from the same source are
+// detected, repeats are counted on the first log instead of being stored.
+func (s *SQLiteLogsStore) SetCollapse(mode logentry.CollapseMode) {
+	s.collapse = mode
+}
+
+// SetSnapshotOnClose makes Close write a snapshot of the database in dir,
+// like the ones written by Snapshot.
+func (s *SQLiteLogsStore) SetSnapshotOnClose(dir string) {
+	s.snapshotDir = dir
+}
+
+func (s *SQLiteLogsStore) Scan(r io.Reader) {
+	s.ScanSource(r, "stdin")
+}
+
+// ScanSource ingests the lines read from r, tagging them with source. It can
+// be called concurrently to ingest several inputs.
+func (s *SQLiteLogsStore) ScanSource(r io.Reader, source string) {
+	s.StartInput()
+	s.StopInput(s.scan(r, source))
+}
+
+// ScanConnection ingests the lines read from a connection of a listener,
+// tagging them with source. The listener is the input, so connections coming
+// and going do not change the status.
+func (s *SQLiteLogsStore) ScanConnection(r io.Reader, source string) {
+	_ = s.scan(r, source)
+}
+
+func (s *SQLiteLogsStore) scan(r io.Reader, name string) error {
+	decoder := s.newDecoder()
+	if reader, ok := decoder.(logentry.ReadDecoder); ok {
+		return s.read(bufio.NewReader(r), reader, name)
+	}
+
+	decode := pipeline.Decode(decoder)
+	scanner := source.NewLineScanner(r)
+	for scanner.Scan() {
+		for _, logLine := range decode.Process(logentry.Log{Line: scanner.Text(), Source: name}) {
+			s.Ingest(logLine)
+		}
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		stdlog.Printf("Failed to read input %s: %v", name, err)
+	}
+	return err
+}
+
+// read ingests the entries of a format that is not read line by line.
+func (s *SQLiteLogsStore) read(r *bufio.Reader, decoder logentry.ReadDecoder, name string) error {
+	for {
+		logLine, err := decoder.Read(r)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
 		if err != nil {
-			return fmt.Errorf("failed to create table: %w", err)
+			stdlog.Printf("Failed to read input %s: %v", name, err)
+			return err
 		}
+		logLine.Source = name
+		s.Ingest(logLine)
+	}
+}
+
+// Ingest stores a log entry and broadcasts it to the clients. Entries from
+// concurrent inputs are ingested one at a time, so that clients receive them
+// in the same order they are stored.
+func (s *SQLiteLogsStore) Ingest(logLine logentry.Log) {
+	s.ingestMu.Lock()
+	defer s.ingestMu.Unlock()
 
-		// Verify table exists and is accessible
-		var count int
-		err = tx.QueryRow("SELECT COUNT(*) FROM logs").Scan(&count)
+	s.writeTee(logLine)
+
+	// the tee gets the original line, the store and clients the processed
+	// entries as safe text
+	for _, processed := range s.pipeline.Process(logLine) {
+		s.store(s.binaryPolicy.Sanitize(processed))
+	}
+}
+
+// Record stores a log written by streamlog itself, like the exit status of a
+// command. It is copied to the tee like the ingested ones, but skips the
+// pipeline so that no processor drops or rewrites it.
+func (s *SQLiteLogsStore) Record(logLine logentry.Log) {
+	s.ingestMu.Lock()
+	defer s.ingestMu.Unlock()
+
+	s.writeTee(logLine)
+	s.store(s.binaryPolicy.Sanitize(logLine))
+}
+
+func (s *SQLiteLogsStore) writeTee(logLine logentry.Log) {
+	if s.tee == nil {
+		return
+	}
+	if err := s.tee.WriteLine(logLine.Line); err != nil {
+		stdlog.Print(err)
+	}
+}
+
+// Import stores the logs of an NDJSON export as they are, going through the
+// import pipeline only, returning how many were stored. Once done, the
+// clients are sent all the logs again, so that the imported ones are shown
+// with their repeats.
+func (s *SQLiteLogsStore) Import(r io.Reader) (int, error) {
+	// the logs ingested so far come first
+	s.writePending()
+
+	s.writeMu.Lock()
+	batchSize := s.batchSize
+	s.writeMu.Unlock()
+
+	var imported int
+	var batch []pendingWrite
+	write := func() error {
+		s.writeMu.Lock()
+		defer s.writeMu.Unlock()
+
+		err := retry(s.ctx, s.counters, func(ctx context.Context) error {
+			_, err := s.writeBatch(ctx, batch)
+			return err
+		})
 		if err != nil {
-			return fmt.Errorf("failed to verify table: %w", err)
+			s.counters.dropped.Add(int64(len(batch)))
+			return fmt.Errorf("failed to write %d logs: %w", len(batch), err)
 		}
+		imported += len(batch)
+		batch = nil
+		return nil
+	}
 
-		// Commit transaction
-		if err := tx.Commit(); err != nil {
-			return fmt.Errorf("failed to commit transaction: %w", err)
+	err := export.ReadNDJSON(r, func(l logentry.Log) error {
+		for _, processed := range s.importPipeline.Process(l) {
+			processed = s.binaryPolicy.Sanitize(processed)
+			batch = append(batch, pendingWrite{log: &processed})
+			if len(batch) >= batchSize {
+				if err := write(); err != nil {
+					return err
+				}
+			}
 		}
 		return nil
-	}, 3)
+	})
+	// the logs read before an invalid record are kept
+	if len(batch) > 0 {
+		if writeErr := write(); writeErr != nil {
+			err = writeErr
+		}
+	}
+
+	if imported > 0 {
+		// repeats are not counted on logs followed by imported ones
+		s.ingestMu.Lock()
+		clear(s.lastBySource)
+		s.ingestMu.Unlock()
+
+		s.resetAll()
+	}
+	return imported, err
+}
+
+// FlushPipeline stores the entries the processors of the pipeline held back
+// and are now due, like the summaries of the suppressed entries.
+func (s *SQLiteLogsStore) FlushPipeline() {
+	s.ingestMu.Lock()
+	defer s.ingestMu.Unlock()
+
+	for _, flushed := range s.pipeline.Flush() {
+		s.store(s.binaryPolicy.Sanitize(flushed))
+	}
+}
+
+// collapsedLog is the last log stored for a source, the one its repeats
+// are counted on.
+type collapsedLog struct {
+	log    *logentry.Log
+	key    string
+	repeat int
+}
+
+// pendingWrite is a write waiting for the next batch, either a log to insert
+// or, when repeat is set, a repeat of an inserted one.
+type pendingWrite struct {
+	log      *logentry.Log
+	repeat   int
+	lastSeen time.Time
+}
+
+// store queues logLine for the next batch, which is written once full or
+// after the batch interval.
+func (s *SQLiteLogsStore) store(logLine logentry.Log) {
+	if s.partitions != nil {
+		s.rollPartition(s.now())
+	}
+
+	write := pendingWrite{log: &logLine}
+
+	if s.collapse != logentry.CollapseOff {
+		key := s.collapse.Key(logLine.Line)
+		last, ok := s.lastBySource[logLine.Source]
+		if ok && last.key == key && last.log.Level == logLine.Level {
+			last.repeat = max(last.repeat, 1) + 1
+			write = pendingWrite{log: last.log, repeat: last.repeat, lastSeen: logLine.Timestamp}
+		} else {
+			last = collapsedLog{log: &logLine, key: key}
+		}
+		s.lastBySource[logLine.Source] = last
+	}
+
+	s.writeMu.Lock()
+	s.pending = append(s.pending, write)
+	full := len(s.pending) >= s.batchSize
+	if !full && s.flushTimer == nil {
+		s.flushTimer = time.AfterFunc(s.batchInterval, s.flush)
+	}
+	s.writeMu.Unlock()
+
+	if full {
+		s.flush()
+	}
+}
+
+// rollPartition starts writing to a new partition once the current one
+// ended, dropping the expired ones and archiving the cold ones in the
+// background. The repeats are counted within a partition.
+func (s *SQLiteLogsStore) rollPartition(now time.Time) {
+	start := s.partitions.Period.start(now)
+	if !start.After(s.partitions.current) {
+		return
+	}
 
+	// the pending logs belong to the partition that ended
+	s.flush()
+
+	db, err := s.partitions.open(s.ctx, s.counters, start)
 	if err != nil {
-		return nil, fmt.Errorf("table creation failed: %w", err)
+		stdlog.Printf("Failed to roll over to a new partition: %v", err)
+		return
 	}
 
-	return &SQLiteLogsStore{
-		db:             db,
-		clients:        make(map[string]chan logentry.Log),
-		filterChangeCh: make(chan struct{}),
-	}, nil
+	s.writeMu.Lock()
+	previous := s.db
+	s.db = db
+	s.partitions.current = start
+	s.writeMu.Unlock()
+
+	if err := previous.Close(); err != nil {
+		stdlog.Printf("Failed to close partition: %v", err)
+	}
+	clear(s.lastBySource)
+	s.partitions.dropExpired(now)
+
+	s.archivers.Add(1)
+	go func() {
+		defer s.archivers.Done()
+		s.partitions.archiveCold(s.ctx, s.counters, now)
+	}()
 }
 
-func (s *SQLiteLogsStore) SetFilter(filter string) {
-	s.filter = filter
-	if len(s.clients) > 0 {
-		s.filterChangeCh <- struct{}{}
+// eachPartition calls query with the database of every partition, oldest
+// first, or with the only database when the logs are not partitioned. The
+// archived partitions are decompressed instead, calling scan with each of
+// their logs. Partitions are opened read-only for the call only, so that
+// rolling over does not wait for the readers.
+func (s *SQLiteLogsStore) eachPartition(query func(db *sql.DB) error, scan func(logentry.Log) error) error {
+	if s.partitions == nil {
+		return query(s.db)
 	}
+
+	files, err := s.partitions.list()
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		// archived in the meantime
+		if _, err := os.Stat(file.path); !file.archived && errors.Is(err, fs.ErrNotExist) {
+			file = partitionFile{start: file.start, path: s.partitions.segmentPath(file.start), archived: true}
+		}
+		// dropped in the meantime
+		if _, err := os.Stat(file.path); errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+
+		if file.archived {
+			if err := readSegment(file.path, scan); err != nil {
+				return err
+			}
+			continue
+		}
+
+		db, err := sql.Open("sqlite3", readOnlyDSN(file.path))
+		if err != nil {
+			return fmt.Errorf("failed to open partition %s: %w", file.path, err)
+		}
+		err = query(db)
+		db.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (s *SQLiteLogsStore) Scan(r io.Reader) {
-	scanner := bufio.NewScanner(r)
-	for scanner.Scan() {
-		line := scanner.Text()
-		logLine := logentry.NewLog(line)
-
-		// Insert log into database with retry
-		err := retryWithBackoff(func() error {
-			_, err := s.db.Exec(
-				"INSERT INTO logs (line, timestamp) VALUES (?, ?)",
-				logLine.Line,
-				logLine.Timestamp,
-			)
-			if err != nil {
-				return fmt.Errorf("failed to insert log: %w", err)
+// flush writes the pending batch and broadcasts the logs written.
+func (s *SQLiteLogsStore) flush() {
+	s.writeMu.Lock()
+	if s.flushTimer != nil {
+		s.flushTimer.Stop()
+		s.flushTimer = nil
+	}
+	s.writeMu.Unlock()
+
+	s.writePending()
+	s.broadcastWritten()
+}
+
+// writePending writes the pending batch in a single transaction. The logs
+// written are broadcast by the next flush, still scheduled, so that it is
+// safe to call it while a client is waiting for the logs.
+func (s *SQLiteLogsStore) writePending() {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	if len(s.pending) == 0 {
+		return
+	}
+	batch := s.pending
+	s.pending = nil
+
+	var written []logentry.Log
+	err := retry(s.ctx, s.counters, func(ctx context.Context) error {
+		var err error
+		written, err = s.writeBatch(ctx, batch)
+		return err
+	})
+
+	if err != nil {
+		s.counters.dropped.Add(int64(len(batch)))
+		stdlog.Printf("Failed to write %d logs: %v", len(batch), err)
+		return
+	}
+	s.unsent = append(s.unsent, written...)
+}
+
+func (s *SQLiteLogsStore) writeBatch(ctx context.Context, batch []pendingWrite) ([]logentry.Log, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	insert, err := tx.PrepareContext(ctx, `
+		INSERT INTO logs (line, timestamp, source, level, fields, repeat, last_timestamp)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare insert: %w", err)
+	}
+	defer insert.Close()
+
+	update, err := tx.PrepareContext(ctx, "UPDATE logs SET repeat = ?, last_timestamp = ? WHERE id = ?")
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare update: %w", err)
+	}
+	defer update.Close()
+
+	written := make([]logentry.Log, 0, len(batch))
+	for _, write := range batch {
+		logLine := *write.log
+
+		if write.repeat > 0 {
+			if _, err := update.ExecContext(ctx, write.repeat, write.lastSeen, logLine.ID); err != nil {
+				return nil, fmt.Errorf("failed to update log: %w", err)
 			}
-			return nil
-		}, 3)
+			logLine.Repeat = write.repeat
+			logLine.LastTimestamp = write.lastSeen
+			logLine.Update = true
+			written = append(written, logLine)
+			continue
+		}
 
+		fields, err := encodeFields(logLine.Fields)
 		if err != nil {
-			stdlog.Printf("Failed to insert log after retries: %v", err)
+			stdlog.Printf("Failed to encode fields: %v", err)
 			continue
 		}
+		// only imported logs come with repeats
+		var lastTimestamp sql.NullTime
+		if logLine.Repeat > 1 {
+			lastTimestamp = sql.NullTime{Time: logLine.LastTimestamp, Valid: true}
+		}
+		result, err := insert.ExecContext(ctx,
+			logLine.Line, logLine.Timestamp, logLine.Source, logLine.Level, fields, max(logLine.Repeat, 1), lastTimestamp,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to insert log: %w", err)
+		}
+		if logLine.ID, err = result.LastInsertId(); err != nil {
+			return nil, fmt.Errorf("failed to read log id: %w", err)
+		}
+		// the repeats written later need the id
+		write.log.ID = logLine.ID
+		written = append(written, logLine)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return written, nil
+}
 
-		// Broadcast to clients if it matches the filter
-		if s.filter == "" || strings.Contains(strings.ToLower(logLine.Line), strings.ToLower(s.filter)) {
-			for _, client := range s.clients {
-				client <- logLine
+// broadcastWritten sends the logs written since the last call to the
+// clients, if they match the filter.
+func (s *SQLiteLogsStore) broadcastWritten() {
+	s.broadcastMu.Lock()
+	defer s.broadcastMu.Unlock()
+
+	s.writeMu.Lock()
+	written := s.unsent
+	s.unsent = nil
+	s.writeMu.Unlock()
+
+	filter := s.currentFilter()
+	s.clientsMu.Lock()
+	defer s.clientsMu.Unlock()
+	for _, logLine := range written {
+		if !filter.matches(logLine) {
+			continue
+		}
+		for uid, client := range s.clients {
+			select {
+			case client <- logLine:
+			default:
+				// the client fell behind, it reloads the logs instead of
+				// blocking the inputs
+				drain(client)
+				s.reset(uid)
 			}
 		}
 	}
 }
 
+// drain discards the logs waiting to be sent on client.
+func drain(client chan logentry.Log) {
+	for {
+		select {
+		case <-client:
+		default:
+			return
+		}
+	}
+}
+
+// encodeFields stores the fields as a JSON object, logs without fields are
+// stored as an empty string.
+func encodeFields(fields map[string]string) (string, error) {
+	if len(fields) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode fields: %w", err)
+	}
+	return string(data), nil
+}
+
+func decodeFields(data string) (map[string]string, error) {
+	if data == "" {
+		return nil, nil
+	}
+	var fields map[string]string
+	if err := json.Unmarshal([]byte(data), &fields); err != nil {
+		return nil, fmt.Errorf("failed to decode fields: %w", err)
+	}
+	return fields, nil
+}
+
+// logFilter selects the logs whose line contains line, from source when set.
+type logFilter struct {
+	line   string
+	source string
+}
+
+// currentFilter returns the filters set by the clients.
+func (s *SQLiteLogsStore) currentFilter() logFilter {
+	s.filterMu.RLock()
+	defer s.filterMu.RUnlock()
+	return s.filter
+}
+
+func (f logFilter) matches(logLine logentry.Log) bool {
+	if f.source != "" && logLine.Source != f.source {
+		return false
+	}
+	return f.line == "" || strings.Contains(strings.ToLower(logLine.Line), strings.ToLower(f.line))
+}
+
+// StartInput records the start of an input, the store is running until it
+// is stopped. ScanSource calls it, listeners are started while open.
+func (s *SQLiteLogsStore) StartInput() {
+	s.statusMu.Lock()
+	s.scanning++
+	s.statusMu.Unlock()
+
+	s.setStatus(IngestionStatus{State: IngestionRunning})
+}
+
+// StopInput records the end of an input. The store keeps running until all
+// the inputs ended, a read error on any of them is reported right away.
+func (s *SQLiteLogsStore) StopInput(err error) {
+	// clients get the last logs before being told the input ended
+	s.flush()
+
+	s.statusMu.Lock()
+	s.scanning--
+	ended := s.scanning == 0 && s.status.State != IngestionError
+	s.statusMu.Unlock()
+
+	switch {
+	case err != nil:
+		s.setStatus(IngestionStatus{State: IngestionError, Error: err.Error()})
+	case ended:
+		s.setStatus(IngestionStatus{State: IngestionEOF})
+	}
+}
+
+func (s *SQLiteLogsStore) setStatus(status IngestionStatus) {
+	status.UpdatedAt = time.Now()
+
+	s.statusMu.Lock()
+	if s.status.State == status.State && s.status.Error == status.Error {
+		s.statusMu.Unlock()
+		return
+	}
+	s.status = status
+	for _, client := range s.statusClients {
+		// a client that did not read the previous status only gets the
+		// latest, sending never blocks on clients gone
+		select {
+		case <-client:
+		default:
+		}
+		client <- status
+	}
+	s.statusMu.Unlock()
+}
+
+func (s *SQLiteLogsStore) Status() IngestionStatus {
+	s.statusMu.Lock()
+	defer s.statusMu.Unlock()
+	return s.status
+}
+
+func (s *SQLiteLogsStore) StatusFor(uid string) chan IngestionStatus {
+	s.statusMu.Lock()
+	defer s.statusMu.Unlock()
+	if _, ok := s.statusClients[uid]; !ok {
+		s.statusClients[uid] = make(chan IngestionStatus, 1)
+	}
+	return s.statusClients[uid]
+}
+
 func (s *SQLiteLogsStore) List() []logentry.Log {
-	var query string
+	s.writePending()
+
+	columns := "line"
 	var args []interface{}
+	filter := s.currentFilter()
+	conditions, conditionArgs := filter.conditions()
 
-	if s.filter != "" {
+	if filter.line != "" {
 		// Use REPLACE to add ANSI highlighting to matched terms
-		query = `
-			SELECT 
+		columns = `
 				REPLACE(
 					REPLACE(
 						line,
@@ -157,73 +905,433 @@ func (s *SQLiteLogsStore) List() []logentry.Log {
 					),
 					UPPER(?),
 					CHAR(27) || '[43m' || UPPER(?) || CHAR(27) || '[0m'
-				) as line,
-				timestamp 
-			FROM logs 
-			WHERE LOWER(line) LIKE LOWER(?) 
-			ORDER BY id ASC`
-		args = []interface{}{s.filter, s.filter, s.filter, s.filter, "%" + s.filter + "%"}
-	} else {
-		query = "SELECT line, timestamp FROM logs ORDER BY id ASC"
+				) as line`
+		args = append(args, filter.line, filter.line, filter.line, filter.line)
 	}
 
+	query := fmt.Sprintf(`
+			SELECT id, %s, timestamp, source, level, fields, repeat, last_timestamp
+			FROM logs
+			WHERE %s
+			ORDER BY id ASC`, columns, strings.Join(conditions, " AND "))
+	args = append(args, conditionArgs...)
+
 	var logs []logentry.Log
-	err := retryWithBackoff(func() error {
-		rows, err := s.db.Query(query, args...)
-		if err != nil {
-			return fmt.Errorf("failed to query logs: %w", err)
+	err := s.eachPartition(func(db *sql.DB) error {
+		var partitionLogs []logentry.Log
+		err := retry(s.ctx, s.counters, func(ctx context.Context) error {
+			var err error
+			partitionLogs, err = queryLogs(ctx, db, query, args...)
+			return err
+		})
+		logs = append(logs, partitionLogs...)
+		return err
+	}, func(logLine logentry.Log) error {
+		if filter.matches(logLine) {
+			logLine.Line = highlight(logLine.Line, filter.line)
+			logs = append(logs, logLine)
 		}
-		defer rows.Close()
+		return nil
+	})
 
-		for rows.Next() {
-			var log logentry.Log
-			err := rows.Scan(&log.Line, &log.Timestamp)
+	if err != nil {
+		stdlog.Printf("Failed to list logs: %v", err)
+		return nil
+	}
+
+	return logs
+}
+
+// exportPageSize is how many logs Export reads at once, the writes can use
+// the connection in between.
+const exportPageSize = 1000
+
+// Export calls write with the logs matching the filter, stored between from
+// (included) and to (excluded) when they are not zero. Unlike List, the logs
+// are read a page at a time and the lines are not highlighted.
+func (s *SQLiteLogsStore) Export(from, to time.Time, write func(logentry.Log) error) error {
+	s.writePending()
+
+	filter := s.currentFilter()
+	conditions, args := filter.conditions()
+	if !from.IsZero() {
+		conditions = append(conditions, "julianday(timestamp) >= julianday(?)")
+		args = append(args, from)
+	}
+	if !to.IsZero() {
+		conditions = append(conditions, "julianday(timestamp) < julianday(?)")
+		args = append(args, to)
+	}
+
+	query := fmt.Sprintf(`
+			SELECT id, line, timestamp, source, level, fields, repeat, last_timestamp
+			FROM logs
+			WHERE id > ? AND %s
+			ORDER BY id ASC
+			LIMIT %d`, strings.Join(conditions, " AND "), exportPageSize)
+
+	// the ids keep increasing across partitions
+	var lastID int64
+	return s.eachPartition(func(db *sql.DB) error {
+		for {
+			var page []logentry.Log
+			err := retry(s.ctx, s.counters, func(ctx context.Context) error {
+				var err error
+				page, err = queryLogs(ctx, db, query, append([]any{lastID}, args...)...)
+				return err
+			})
 			if err != nil {
-				return fmt.Errorf("failed to scan log: %w", err)
+				return fmt.Errorf("failed to export logs: %w", err)
+			}
+
+			for _, logLine := range page {
+				if err := write(logLine); err != nil {
+					return err
+				}
 			}
-			logs = append(logs, log)
+			if len(page) < exportPageSize {
+				return nil
+			}
+			lastID = page[len(page)-1].ID
+		}
+	}, func(logLine logentry.Log) error {
+		if !filter.matches(logLine) ||
+			(!from.IsZero() && logLine.Timestamp.Before(from)) ||
+			(!to.IsZero() && !logLine.Timestamp.Before(to)) {
+			return nil
 		}
-		return rows.Err()
-	}, 10)
+		return write(logLine)
+	})
+}
+
+// conditions returns the conditions selecting the logs matching the filter,
+// with their arguments.
+func (f logFilter) conditions() ([]string, []any) {
+	conditions := []string{"1 = 1"}
+	var args []any
+
+	if f.line != "" {
+		conditions = append(conditions, "LOWER(line) LIKE LOWER(?)")
+		args = append(args, "%"+f.line+"%")
+	}
+	if f.source != "" {
+		conditions = append(conditions, "source = ?")
+		args = append(args, f.source)
+	}
+	return conditions, args
+}
+
+// highlight marks the lower and upper case occurrences of filter in line,
+// like the query of List does.
+func highlight(line, filter string) string {
+	if filter == "" {
+		return line
+	}
+	for _, term := range []string{strings.ToLower(filter), strings.ToUpper(filter)} {
+		line = strings.ReplaceAll(line, term, "\x1b[43m"+term+"\x1b[0m")
+	}
+	return line
+}
 
+// queryLogs returns the logs selected by query, which has to select the
+// columns of the logs table in order.
+func queryLogs(ctx context.Context, db *sql.DB, query string, args ...any) ([]logentry.Log, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
 	if err != nil {
-		stdlog.Printf("Failed to list logs after retries: %v", err)
-		return nil
+		return nil, fmt.Errorf("failed to query logs: %w", err)
 	}
+	defer rows.Close()
 
-	return logs
+	var logs []logentry.Log
+	for rows.Next() {
+		var log logentry.Log
+		var fields string
+		var repeat int
+		var lastTimestamp sql.NullTime
+		err := rows.Scan(&log.ID, &log.Line, &log.Timestamp, &log.Source, &log.Level, &fields, &repeat, &lastTimestamp)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan log: %w", err)
+		}
+		if repeat > 1 {
+			log.Repeat = repeat
+			log.LastTimestamp = lastTimestamp.Time
+		}
+		if log.Fields, err = decodeFields(fields); err != nil {
+			return nil, err
+		}
+		logs = append(logs, log)
+	}
+	return logs, rows.Err()
 }
 
 func (s *SQLiteLogsStore) Disconnect(uid string) {
+	s.clientsMu.Lock()
 	delete(s.clients, uid)
+	s.clientsMu.Unlock()
+	s.statusMu.Lock()
+	delete(s.statusClients, uid)
+	s.statusMu.Unlock()
+	s.resetMu.Lock()
+	delete(s.resetClients, uid)
+	s.resetMu.Unlock()
 	stdlog.Printf("Client %s disconnected", uid)
 }
 
 func (s *SQLiteLogsStore) LineFor(uid string) chan logentry.Log {
+	s.clientsMu.Lock()
+	defer s.clientsMu.Unlock()
 	if _, ok := s.clients[uid]; !ok {
-		s.clients[uid] = make(chan logentry.Log)
+		s.clients[uid] = make(chan logentry.Log, clientBacklog)
 	}
 	return s.clients[uid]
 }
 
 func (s *SQLiteLogsStore) Clients() []string {
+	s.clientsMu.Lock()
+	defer s.clientsMu.Unlock()
 	return slices.Sorted(maps.Keys(s.clients))
 }
 
-func (s *SQLiteLogsStore) FilterChangeFor() chan struct{} {
-	return s.filterChangeCh
+// FilterChangeFor returns the channel uid is told on to reload the logs.
+func (s *SQLiteLogsStore) FilterChangeFor(uid string) chan struct{} {
+	s.resetMu.Lock()
+	defer s.resetMu.Unlock()
+	if _, ok := s.resetClients[uid]; !ok {
+		s.resetClients[uid] = make(chan struct{}, 1)
+	}
+	return s.resetClients[uid]
+}
+
+// resetAll tells every client to reload the logs. A client with a reload
+// pending is not told twice, so that sending never blocks.
+func (s *SQLiteLogsStore) resetAll() {
+	s.resetMu.Lock()
+	defer s.resetMu.Unlock()
+	for uid := range s.resetClients {
+		s.resetLocked(uid)
+	}
 }
 
+// reset tells uid to reload the logs.
+func (s *SQLiteLogsStore) reset(uid string) {
+	s.resetMu.Lock()
+	defer s.resetMu.Unlock()
+	s.resetLocked(uid)
+}
+
+func (s *SQLiteLogsStore) resetLocked(uid string) {
+	if _, ok := s.resetClients[uid]; !ok {
+		s.resetClients[uid] = make(chan struct{}, 1)
+	}
+	select {
+	case s.resetClients[uid] <- struct{}{}:
+	default:
+	}
+}
+
+// Metrics returns the counts of the errors of the database operations.
+func (s *SQLiteLogsStore) Metrics() StoreMetrics {
+	return s.counters.metrics()
+}
+
+// Close writes the pending logs and waits for the partitions being archived,
+// then cancels the retries still running.
 func (s *SQLiteLogsStore) Close() error {
+	s.writePending()
+	s.archivers.Wait()
+	if s.snapshotDir != "" {
+		path := SnapshotPath(s.snapshotDir, time.Now())
+		if err := s.Snapshot(path); err != nil {
+			stdlog.Printf("Failed to write snapshot: %v", err)
+		} else {
+			stdlog.Printf("Wrote snapshot %s", path)
+		}
+	}
+	s.cancel()
 	return s.db.Close()
 }
 
+// SnapshotPath returns the path in dir of the snapshot taken at t.
+func SnapshotPath(dir string, t time.Time) string {
+	return filepath.Join(dir, "streamlog-"+t.UTC().Format("20060102T150405.000Z")+".db")
+}
+
+// Snapshot writes a consistent copy of the database to path with the SQLite
+// online backup API, including the logs still waiting for their batch. The
+// copy is written next to path first, so path is never left half written.
+func (s *SQLiteLogsStore) Snapshot(path string) error {
+	s.writePending()
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".streamlog-snapshot-*.db")
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot: %w", err)
+	}
+	_ = tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	// rolling over to a new partition waits for the backup
+	s.writeMu.Lock()
+	var current time.Time
+	if s.partitions != nil {
+		current = s.partitions.current
+	}
+	err = retry(s.ctx, s.counters, func(ctx context.Context) error {
+		return s.backup(ctx, tmp.Name())
+	})
+	s.writeMu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+
+	if s.partitions != nil {
+		if err := s.mergePartitions(tmp.Name(), current); err != nil {
+			return fmt.Errorf("failed to write snapshot: %w", err)
+		}
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	return nil
+}
+
+// mergePartitions copies the logs of the partitions before current to the
+// database at path, so that a snapshot holds all the logs.
+func (s *SQLiteLogsStore) mergePartitions(path string, current time.Time) error {
+	files, err := s.partitions.list()
+	if err != nil {
+		return err
+	}
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	// attached databases belong to a connection
+	conn, err := db.Conn(s.ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	for _, file := range files {
+		if !file.start.Before(current) {
+			continue
+		}
+		if file.archived {
+			err = copySegment(s.ctx, conn, file.path)
+		} else {
+			err = retry(s.ctx, s.counters, func(ctx context.Context) error {
+				if _, err := conn.ExecContext(ctx, "ATTACH DATABASE ? AS previous", readOnlyDSN(file.path)); err != nil {
+					return fmt.Errorf("failed to attach partition: %w", err)
+				}
+				defer conn.ExecContext(context.Background(), "DETACH DATABASE previous")
+
+				_, err := conn.ExecContext(ctx, `
+					INSERT INTO logs (id, line, timestamp, source, level, fields, repeat, last_timestamp)
+					SELECT id, line, timestamp, source, level, fields, repeat, last_timestamp
+					FROM previous.logs`)
+				return err
+			})
+		}
+		if err != nil {
+			return fmt.Errorf("failed to copy partition %s: %w", file.path, err)
+		}
+	}
+	return nil
+}
+
+// copySegment inserts the logs of the segment at path, ids included, in a
+// single transaction.
+func copySegment(ctx context.Context, conn *sql.Conn, path string) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	insert, err := tx.PrepareContext(ctx, `
+		INSERT INTO logs (id, line, timestamp, source, level, fields, repeat, last_timestamp)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare insert: %w", err)
+	}
+	defer insert.Close()
+
+	err = readSegment(path, func(logLine logentry.Log) error {
+		fields, err := encodeFields(logLine.Fields)
+		if err != nil {
+			return err
+		}
+		var lastTimestamp sql.NullTime
+		if logLine.Repeat > 1 {
+			lastTimestamp = sql.NullTime{Time: logLine.LastTimestamp, Valid: true}
+		}
+		_, err = insert.ExecContext(ctx,
+			logLine.ID, logLine.Line, logLine.Timestamp, logLine.Source, logLine.Level, fields, max(logLine.Repeat, 1), lastTimestamp,
+		)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// backup copies the whole database to the database at path in a single
+// step, holding the only connection of the store so no write happens
+// in between.
+func (s *SQLiteLogsStore) backup(ctx context.Context, path string) error {
+	dst, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	dstConn, err := dst.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer dstConn.Close()
+
+	srcConn, err := s.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer srcConn.Close()
+
+	return dstConn.Raw(func(dstDriverConn any) error {
+		return srcConn.Raw(func(srcDriverConn any) error {
+			backup, err := dstDriverConn.(*sqlite3.SQLiteConn).Backup("main", srcDriverConn.(*sqlite3.SQLiteConn), "main")
+			if err != nil {
+				return err
+			}
+			if _, err := backup.Step(-1); err != nil {
+				_ = backup.Finish()
+				return err
+			}
+			return backup.Finish()
+		})
+	})
+}
+
 type Store interface {
 	SetFilter(filter string)
+	SetSourceFilter(source string)
+	Sources() []string
 	Scan(r io.Reader)
+	Ingest(logLine logentry.Log)
 	List() []logentry.Log
 	Disconnect(uid string)
 	LineFor(uid string) chan logentry.Log
 	Clients() []string
-	FilterChangeFor() chan struct{}
+	FilterChangeFor(uid string) chan struct{}
+	Status() IngestionStatus
+	StatusFor(uid string) chan IngestionStatus
+	Metrics() StoreMetrics
+	Export(from, to time.Time, write func(logentry.Log) error) error
+	Import(r io.Reader) (int, error)
+	Snapshot(path string) error
 }
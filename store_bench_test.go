@@ -0,0 +1,105 @@
+package main_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	main "github.com/carlo-colombo/streamlog_go"
+	"github.com/carlo-colombo/streamlog_go/logentry"
+)
+
+func BenchmarkIngest(b *testing.B) {
+	databases := []struct {
+		name string
+		path func(b *testing.B) string
+	}{
+		{"memory", func(*testing.B) string { return ":memory:" }},
+		{"file", func(b *testing.B) string { return filepath.Join(b.TempDir(), "logs.db") }},
+	}
+
+	for _, database := range databases {
+		for _, batchSize := range []int{1, 100, main.DefaultBatchSize} {
+			b.Run(fmt.Sprintf("%s/batch=%d", database.name, batchSize), func(b *testing.B) {
+				store, err := main.NewSQLiteStore(database.path(b))
+				if err != nil {
+					b.Fatal(err)
+				}
+				defer store.Close()
+				store.SetBatch(batchSize, main.DefaultBatchInterval)
+
+				logLine := logentry.NewLog("GET /api/v1/items?page=2 200 12ms")
+				logLine.Source = "bench"
+
+				b.ReportAllocs()
+				b.ResetTimer()
+				for range b.N {
+					store.Ingest(logLine)
+				}
+				store.List()
+				b.StopTimer()
+
+				b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "lines/s")
+			})
+		}
+	}
+}
+
+// BenchmarkArchive archives a day of logs, reporting how much smaller the
+// segment is than the database of the partition.
+func BenchmarkArchive(b *testing.B) {
+	const lines = 50_000
+	partitioning := main.Partitioning{Period: main.PartitionDay, ArchiveAfter: time.Hour}
+	methods := []string{"GET", "POST", "PUT", "DELETE"}
+	users := []string{"alice", "bob", "carol", "dave", "erin"}
+
+	var databaseSize, segmentSize int64
+	for range b.N {
+		b.StopTimer()
+		dir := b.TempDir()
+		clock := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+		now := func() time.Time { return clock }
+
+		store, err := main.NewPartitionedSQLiteStore(filepath.Join(dir, "logs.db"), partitioning, now)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for i := range lines {
+			store.Ingest(logentry.Log{
+				Line: fmt.Sprintf("%s /api/v1/items/%d?page=%d %d %dms user=%s request_id=%08x",
+					methods[i%len(methods)], i*7919%100_000, i%50, []int{200, 200, 200, 404, 500}[i%5], i*31%400, users[i%len(users)], i*2654435761),
+				Timestamp: clock.Add(time.Duration(i) * time.Second),
+				Source:    fmt.Sprintf("worker-%d", i%8),
+			})
+		}
+		if err := store.Close(); err != nil {
+			b.Fatal(err)
+		}
+		databaseSize = fileSize(b, filepath.Join(dir, "logs-2026-10-18.db"))
+
+		// the partition is archived when the store is opened the day after
+		clock = clock.AddDate(0, 0, 1).Add(partitioning.ArchiveAfter)
+		b.StartTimer()
+		store, err = main.NewPartitionedSQLiteStore(filepath.Join(dir, "logs.db"), partitioning, now)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.StopTimer()
+		_ = store.Close()
+		segmentSize = fileSize(b, filepath.Join(dir, "logs-2026-10-18.ndjson.zst"))
+	}
+
+	b.ReportMetric(float64(databaseSize), "database-bytes")
+	b.ReportMetric(float64(segmentSize), "segment-bytes")
+	b.ReportMetric(float64(databaseSize)/float64(segmentSize), "x-smaller")
+}
+
+func fileSize(b *testing.B, path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return info.Size()
+}
@@ -0,0 +1,53 @@
+package syslog
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"strconv"
+)
+
+// maxFrameLength bounds the length announced by an octet-counted frame.
+const maxFrameLength = 64 * 1024
+
+var errInvalidFrame = errors.New("invalid octet-counted frame")
+
+// ScanFrames is a bufio.SplitFunc for syslog over TCP (RFC 6587). Frames
+// starting with a digit are octet-counted ("LEN SP MSG"), any other frame is
+// terminated by a newline. Both framings can be mixed on a connection.
+func ScanFrames(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if len(data) == 0 {
+		return 0, nil, nil
+	}
+
+	// trailers left between octet-counted frames by some senders
+	if data[0] == '\n' || data[0] == '\r' || data[0] == 0 {
+		return 1, nil, nil
+	}
+
+	if data[0] < '1' || data[0] > '9' {
+		return bufio.ScanLines(data, atEOF)
+	}
+
+	space := bytes.IndexByte(data, ' ')
+	if space < 0 {
+		if atEOF || len(data) > len(strconv.Itoa(maxFrameLength)) {
+			return 0, nil, errInvalidFrame
+		}
+		return 0, nil, nil
+	}
+
+	length, err := strconv.Atoi(string(data[:space]))
+	if err != nil || length > maxFrameLength {
+		return 0, nil, errInvalidFrame
+	}
+
+	end := space + 1 + length
+	if len(data) < end {
+		if atEOF {
+			return 0, nil, errInvalidFrame
+		}
+		return 0, nil, nil
+	}
+	return end, data[space+1 : end], nil
+}
@@ -0,0 +1,42 @@
+package syslog_test
+
+import (
+	"bufio"
+	"strings"
+
+	"github.com/carlo-colombo/streamlog_go/syslog"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Syslog/ScanFrames", func() {
+	scan := func(stream string) ([]string, error) {
+		scanner := bufio.NewScanner(strings.NewReader(stream))
+		scanner.Split(syslog.ScanFrames)
+
+		var frames []string
+		for scanner.Scan() {
+			frames = append(frames, scanner.Text())
+		}
+		return frames, scanner.Err()
+	}
+
+	It("splits newline terminated frames", func() {
+		Expect(scan("<13>first\r\n<13>second\n<13>last")).To(Equal([]string{"<13>first", "<13>second", "<13>last"}))
+	})
+
+	It("splits octet-counted frames, including newlines in the message", func() {
+		Expect(scan("9 <13>a\nb\nc8 <13>last\n")).To(Equal([]string{"<13>a\nb\nc", "<13>last"}))
+	})
+
+	It("accepts both framings on the same stream", func() {
+		Expect(scan("<13>lf framed\n9 <13>octet")).To(Equal([]string{"<13>lf framed", "<13>octet"}))
+	})
+
+	It("fails on truncated octet-counted frames", func() {
+		frames, err := scan("9 <13>oc")
+
+		Expect(frames).To(BeEmpty())
+		Expect(err).To(MatchError("invalid octet-counted frame"))
+	})
+})
@@ -0,0 +1,201 @@
+// Package syslog receives syslog messages over UDP and TCP and parses them
+// into log entries.
+package syslog
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/carlo-colombo/streamlog_go/logentry"
+)
+
+var facilities = []string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+	"uucp", "cron", "authpriv", "ftp", "ntp", "security", "console", "solaris-cron",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+var severities = []string{
+	"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug",
+}
+
+var errNoPriority = errors.New("missing priority")
+
+// Parse reads a RFC 5424 or RFC 3164 message. The message becomes the line
+// of the entry and the header its fields; the timestamp of the message is
+// used when present. Messages that cannot be parsed are kept as they are.
+func Parse(data []byte) logentry.Log {
+	data = bytes.TrimRight(data, "\r\n\x00")
+
+	l := logentry.NewLog(string(data))
+
+	priority, rest, err := parsePriority(data)
+	if err != nil {
+		return l
+	}
+
+	l.Level = logentry.LevelFromPriority(priority % 8)
+	l.Fields = map[string]string{
+		"priority": strconv.Itoa(priority),
+		"facility": facilities[priority/8],
+		"severity": severities[priority%8],
+	}
+
+	if len(rest) > 1 && rest[0] == '1' && rest[1] == ' ' {
+		parse5424(rest[2:], &l)
+	} else {
+		parse3164(rest, &l)
+	}
+	return l
+}
+
+// parsePriority reads the <PRI> at the beginning of a message.
+func parsePriority(data []byte) (int, []byte, error) {
+	if len(data) < 3 || data[0] != '<' {
+		return 0, nil, errNoPriority
+	}
+	end := bytes.IndexByte(data[:min(len(data), 5)], '>')
+	if end < 2 {
+		return 0, nil, errNoPriority
+	}
+	priority, err := strconv.Atoi(string(data[1:end]))
+	if err != nil || priority < 0 || priority >= len(facilities)*8 {
+		return 0, nil, errNoPriority
+	}
+	return priority, data[end+1:], nil
+}
+
+// parse5424 reads what follows "<PRI>1 ":
+// TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA [MSG]
+func parse5424(data []byte, l *logentry.Log) {
+	header := make([]string, 5)
+	for i := range header {
+		header[i], data = nextToken(data)
+	}
+
+	if ts, err := time.Parse(time.RFC3339Nano, header[0]); err == nil {
+		l.Timestamp = ts
+	}
+	for i, name := range []string{"hostname", "app_name", "procid", "msgid"} {
+		if value := header[i+1]; value != "" && value != "-" {
+			l.Fields[name] = value
+		}
+	}
+
+	data = parseStructuredData(data, l.Fields)
+	if len(data) > 0 && data[0] == ' ' {
+		data = data[1:]
+	}
+	l.Line = string(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))
+}
+
+// parseStructuredData adds the params of the SD-ELEMENTs as fields named
+// after their SD-ID, e.g. `[origin ip="10.0.0.1"]` as "origin.ip".
+func parseStructuredData(data []byte, fields map[string]string) []byte {
+	if len(data) > 0 && data[0] == '-' {
+		return data[1:]
+	}
+
+	for len(data) > 0 && data[0] == '[' {
+		var id string
+		id, data = nextName(data[1:])
+
+		for len(data) > 0 && data[0] == ' ' {
+			var name, value string
+			name, data = nextName(data[1:])
+			if len(data) < 2 || data[0] != '=' || data[1] != '"' {
+				return data
+			}
+			value, data = quotedValue(data[2:])
+			fields[id+"."+name] = value
+		}
+
+		if len(data) == 0 || data[0] != ']' {
+			return data
+		}
+		data = data[1:]
+	}
+	return data
+}
+
+// nextName reads a SD-ID or PARAM-NAME, up to a space, '=' or ']'.
+func nextName(data []byte) (string, []byte) {
+	end := bytes.IndexAny(data, " =]")
+	if end < 0 {
+		return string(data), nil
+	}
+	return string(data[:end]), data[end:]
+}
+
+// quotedValue reads a PARAM-VALUE up to the closing quote, unescaping '"',
+// '\' and ']'.
+func quotedValue(data []byte) (string, []byte) {
+	var value strings.Builder
+	for i := 0; i < len(data); i++ {
+		switch {
+		case data[i] == '\\' && i+1 < len(data) && bytes.IndexByte([]byte(`"\]`), data[i+1]) >= 0:
+			i++
+			value.WriteByte(data[i])
+		case data[i] == '"':
+			return value.String(), data[i+1:]
+		default:
+			value.WriteByte(data[i])
+		}
+	}
+	return value.String(), nil
+}
+
+func nextToken(data []byte) (string, []byte) {
+	end := bytes.IndexByte(data, ' ')
+	if end < 0 {
+		return string(data), nil
+	}
+	return string(data[:end]), data[end+1:]
+}
+
+// parse3164 reads what follows "<PRI>": TIMESTAMP HOSTNAME TAG: MSG, where
+// TIMESTAMP is "Jan  2 15:04:05" and every part is optional in practice.
+func parse3164(data []byte, l *logentry.Log) {
+	if len(data) >= len(time.Stamp) {
+		if ts, err := time.ParseInLocation(time.Stamp, string(data[:len(time.Stamp)]), time.Local); err == nil {
+			now := time.Now()
+			ts = ts.AddDate(now.Year(), 0, 0)
+			// messages from the end of December received in January
+			if ts.After(now.Add(24 * time.Hour)) {
+				ts = ts.AddDate(-1, 0, 0)
+			}
+			l.Timestamp = ts
+			data = bytes.TrimPrefix(data[len(time.Stamp):], []byte(" "))
+
+			// the hostname is left out by some senders, the tag ends with ':'
+			if hostname, rest := nextToken(data); rest != nil && !strings.HasSuffix(hostname, ":") {
+				l.Fields["hostname"] = hostname
+				data = rest
+			}
+		}
+	}
+
+	tag, msg, found := bytes.Cut(data, []byte(": "))
+	if found && isTag(string(tag)) {
+		name, pid, _ := strings.Cut(string(tag), "[")
+		l.Fields["app_name"] = name
+		if pid = strings.TrimSuffix(pid, "]"); pid != "" {
+			l.Fields["procid"] = pid
+		}
+		data = msg
+	}
+
+	l.Line = string(data)
+}
+
+// isTag reports whether s looks like a TAG, e.g. "sshd" or "sshd[123]".
+func isTag(s string) bool {
+	if s == "" || strings.Contains(s, " ") {
+		return false
+	}
+	name, pid, hasPID := strings.Cut(s, "[")
+	return name != "" && (!hasPID || strings.HasSuffix(pid, "]"))
+}
@@ -0,0 +1,95 @@
+package syslog_test
+
+import (
+	"time"
+
+	"github.com/carlo-colombo/streamlog_go/syslog"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Syslog/Parse", func() {
+	Describe("RFC 5424", func() {
+		It("parses the header into fields", func() {
+			l := syslog.Parse([]byte(`<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog 1234 ID47 - An application event log entry`))
+
+			Expect(l.Line).To(Equal("An application event log entry"))
+			Expect(l.Level).To(Equal("notice"))
+			Expect(l.Timestamp).To(Equal(time.Date(2003, 10, 11, 22, 14, 15, 3_000_000, time.UTC)))
+			Expect(l.Fields).To(Equal(map[string]string{
+				"priority": "165",
+				"facility": "local4",
+				"severity": "notice",
+				"hostname": "mymachine.example.com",
+				"app_name": "evntslog",
+				"procid":   "1234",
+				"msgid":    "ID47",
+			}))
+		})
+
+		It("parses the structured data", func() {
+			l := syslog.Parse([]byte(`<165>1 2003-10-11T22:14:15.003Z host app - - [exampleSDID@32473 iut="3" eventSource="Appli\"cation\]"][origin ip="10.0.0.1"] ` + "\xef\xbb\xbf" + `message`))
+
+			Expect(l.Line).To(Equal("message"))
+			Expect(l.Fields).To(SatisfyAll(
+				HaveKeyWithValue("exampleSDID@32473.iut", "3"),
+				HaveKeyWithValue("exampleSDID@32473.eventSource", `Appli"cation]`),
+				HaveKeyWithValue("origin.ip", "10.0.0.1"),
+				Not(HaveKey("procid")),
+				Not(HaveKey("msgid")),
+			))
+		})
+
+		It("accepts messages without MSG", func() {
+			l := syslog.Parse([]byte(`<34>1 2003-10-11T22:14:15Z host app - - -`))
+
+			Expect(l.Line).To(BeEmpty())
+			Expect(l.Fields).To(HaveKeyWithValue("severity", "crit"))
+		})
+	})
+
+	Describe("RFC 3164", func() {
+		It("parses the header into fields", func() {
+			l := syslog.Parse([]byte("<34>Oct 11 22:14:15 mymachine su[42]: 'su root' failed for lonvick on /dev/pts/8\n"))
+
+			Expect(l.Line).To(Equal("'su root' failed for lonvick on /dev/pts/8"))
+			Expect(l.Level).To(Equal("critical"))
+			Expect(l.Timestamp.Month()).To(Equal(time.October))
+			Expect(l.Timestamp.Day()).To(Equal(11))
+			Expect(l.Timestamp.Hour()).To(Equal(22))
+			Expect(l.Fields).To(Equal(map[string]string{
+				"priority": "34",
+				"facility": "auth",
+				"severity": "crit",
+				"hostname": "mymachine",
+				"app_name": "su",
+				"procid":   "42",
+			}))
+		})
+
+		It("accepts messages without hostname", func() {
+			l := syslog.Parse([]byte("<13>Feb  5 17:32:18 sshd: connection closed"))
+
+			Expect(l.Line).To(Equal("connection closed"))
+			Expect(l.Fields).To(SatisfyAll(
+				HaveKeyWithValue("app_name", "sshd"),
+				Not(HaveKey("hostname")),
+			))
+		})
+
+		It("accepts messages with only a priority", func() {
+			l := syslog.Parse([]byte("<13>just a message"))
+
+			Expect(l.Line).To(Equal("just a message"))
+			Expect(l.Fields).To(HaveKeyWithValue("facility", "user"))
+		})
+	})
+
+	It("keeps messages without priority as they are", func() {
+		l := syslog.Parse([]byte("not syslog at all"))
+
+		Expect(l.Line).To(Equal("not syslog at all"))
+		Expect(l.Fields).To(BeNil())
+		Expect(l.Timestamp).To(BeTemporally("~", time.Now(), time.Second))
+	})
+})
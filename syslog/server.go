@@ -0,0 +1,119 @@
+package syslog
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+
+	"github.com/carlo-colombo/streamlog_go/logentry"
+)
+
+// Server receives syslog messages on the same port over UDP and TCP.
+type Server struct {
+	tcp net.Listener
+	udp net.PacketConn
+
+	wg sync.WaitGroup
+}
+
+// Listen binds addr for TCP, and the same port for UDP.
+func Listen(addr string) (*Server, error) {
+	tcp, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for syslog over TCP: %w", err)
+	}
+
+	// when addr has port 0 use the one picked for TCP
+	udp, err := net.ListenPacket("udp", tcp.Addr().String())
+	if err != nil {
+		_ = tcp.Close()
+		return nil, fmt.Errorf("failed to listen for syslog over UDP: %w", err)
+	}
+
+	return &Server{tcp: tcp, udp: udp}, nil
+}
+
+func (s *Server) TCPAddr() net.Addr {
+	return s.tcp.Addr()
+}
+
+func (s *Server) UDPAddr() net.Addr {
+	return s.udp.LocalAddr()
+}
+
+// Serve passes every message received to ingest, tagged with the address of
+// the sender, until the server is closed.
+func (s *Server) Serve(ingest func(logentry.Log)) {
+	s.wg.Add(2)
+	go func() {
+		defer s.wg.Done()
+		s.serveUDP(ingest)
+	}()
+	go func() {
+		defer s.wg.Done()
+		s.serveTCP(ingest)
+	}()
+	s.wg.Wait()
+}
+
+func (s *Server) serveUDP(ingest func(logentry.Log)) {
+	buffer := make([]byte, 64*1024)
+	for {
+		n, addr, err := s.udp.ReadFrom(buffer)
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+		if err != nil {
+			log.Printf("Failed to read syslog datagram: %v", err)
+			continue
+		}
+		ingest(tagged(Parse(buffer[:n]), addr))
+	}
+}
+
+func (s *Server) serveTCP(ingest func(logentry.Log)) {
+	for {
+		conn, err := s.tcp.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+		if err != nil {
+			log.Printf("Failed to accept syslog connection: %v", err)
+			continue
+		}
+
+		go func() {
+			defer conn.Close()
+
+			scanner := bufio.NewScanner(conn)
+			// room for the octet count in front of the longest frame
+			scanner.Buffer(make([]byte, 4096), 2*maxFrameLength)
+			scanner.Split(ScanFrames)
+			for scanner.Scan() {
+				ingest(tagged(Parse(scanner.Bytes()), conn.RemoteAddr()))
+			}
+			if err := scanner.Err(); err != nil {
+				log.Printf("Failed to read syslog from %s: %v", conn.RemoteAddr(), err)
+			}
+		}()
+	}
+}
+
+// tagged sets the source of l to the host that sent it.
+func tagged(l logentry.Log, addr net.Addr) logentry.Log {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		host = addr.String()
+	}
+	l.Source = "syslog:" + host
+	return l
+}
+
+// Close stops accepting messages, connections already open are served
+// until the client closes them.
+func (s *Server) Close() error {
+	return errors.Join(s.tcp.Close(), s.udp.Close())
+}
@@ -0,0 +1,57 @@
+package syslog_test
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/carlo-colombo/streamlog_go/logentry"
+	"github.com/carlo-colombo/streamlog_go/syslog"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Syslog/Server", func() {
+	var server *syslog.Server
+	var logs chan logentry.Log
+
+	BeforeEach(func() {
+		var err error
+		server, err = syslog.Listen("127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+
+		logs = make(chan logentry.Log, 10)
+		go server.Serve(func(l logentry.Log) { logs <- l })
+
+		DeferCleanup(server.Close)
+	})
+
+	It("receives messages over UDP", func() {
+		conn, err := net.Dial("udp", server.UDPAddr().String())
+		Expect(err).ToNot(HaveOccurred())
+		defer conn.Close()
+
+		_, err = fmt.Fprint(conn, "<34>1 2003-10-11T22:14:15Z router app - - - over udp")
+		Expect(err).ToNot(HaveOccurred())
+
+		Eventually(logs).Should(Receive(SatisfyAll(
+			HaveField("Line", "over udp"),
+			HaveField("Source", "syslog:127.0.0.1"),
+			HaveField("Fields", HaveKeyWithValue("hostname", "router")),
+		)))
+	})
+
+	It("receives messages over TCP", func() {
+		conn, err := net.Dial("tcp", server.TCPAddr().String())
+		Expect(err).ToNot(HaveOccurred())
+		defer conn.Close()
+
+		_, err = fmt.Fprint(conn, "<13>Oct 11 22:14:15 host app: first\n15 <13>app: second")
+		Expect(err).ToNot(HaveOccurred())
+
+		Eventually(logs).Should(Receive(HaveField("Line", "first")))
+		Eventually(logs).Should(Receive(SatisfyAll(
+			HaveField("Line", "second"),
+			HaveField("Source", "syslog:127.0.0.1"),
+		)))
+	})
+})
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+)
+
+// Tee copies ingested lines to a writer, so streamlog can sit in the middle
+// of a pipeline. When match is set only the matching lines are copied.
+type Tee struct {
+	writer io.Writer
+	match  *regexp.Regexp
+}
+
+func NewTee(w io.Writer, match *regexp.Regexp) *Tee {
+	return &Tee{
+		writer: w,
+		match:  match,
+	}
+}
+
+func (t *Tee) WriteLine(line string) error {
+	if t.match != nil && !t.match.MatchString(line) {
+		return nil
+	}
+	if _, err := fmt.Fprintln(t.writer, line); err != nil {
+		return fmt.Errorf("failed to tee line: %w", err)
+	}
+	return nil
+}
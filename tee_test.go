@@ -0,0 +1,40 @@
+package main_test
+
+import (
+	"regexp"
+
+	main "github.com/carlo-colombo/streamlog_go"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("Tee", func() {
+	It("writes every line followed by a newline", func() {
+		buffer := gbytes.NewBuffer()
+		tee := main.NewTee(buffer, nil)
+
+		Expect(tee.WriteLine("first")).To(Succeed())
+		Expect(tee.WriteLine("second")).To(Succeed())
+
+		Expect(buffer.Contents()).To(Equal([]byte("first\nsecond\n")))
+	})
+
+	It("writes only the lines matching the pattern", func() {
+		buffer := gbytes.NewBuffer()
+		tee := main.NewTee(buffer, regexp.MustCompile(`(?i)error`))
+
+		Expect(tee.WriteLine("ERROR something broke")).To(Succeed())
+		Expect(tee.WriteLine("all good")).To(Succeed())
+
+		Expect(buffer.Contents()).To(Equal([]byte("ERROR something broke\n")))
+	})
+
+	It("returns an error when the writer is closed", func() {
+		buffer := gbytes.NewBuffer()
+		Expect(buffer.Close()).To(Succeed())
+
+		Expect(main.NewTee(buffer, nil).WriteLine("line")).
+			To(MatchError(ContainSubstring("failed to tee line")))
+	})
+})
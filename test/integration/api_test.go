@@ -6,7 +6,10 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strings"
+	"syscall"
 
 	"github.com/carlo-colombo/streamlog_go/test/utils"
 	. "github.com/onsi/ginkgo/v2"
@@ -38,6 +41,161 @@ var _ = Describe("Test/Integration/Streamlog", func() {
 		Eventually(session.Err).Should(Say("Failed to start server: listen tcp :" + port + ": bind: address already in use"))
 	})
 
+	It("keeps serving after stdin is closed", func() {
+		Expect(stdinWriter.Close()).To(Succeed())
+
+		Eventually(func() (*http.Response, error) {
+			return http.Get(targetUrl + "/status")
+		}).Should(HaveHTTPBody(ContainSubstring(`"state":"eof"`)))
+		Consistently(session).ShouldNot(gexec.Exit())
+	})
+
+	It("exits when stdin is closed with --exit-on-eof", func() {
+		stdinReader, stdinWriter = io.Pipe()
+
+		session = runBin([]string{"--exit-on-eof"}, stdinReader)
+		Eventually(session.Err).Should(Say("Starting on http://localhost:"))
+
+		Expect(stdinWriter.Close()).To(Succeed())
+
+		Eventually(session).Should(gexec.Exit(0))
+	})
+
+	It("waits for the listeners to close with --exit-on-eof", func() {
+		stdinReader, stdinWriter = io.Pipe()
+
+		session = runBin([]string{"--exit-on-eof", "--tcp-listen", "127.0.0.1:0"}, stdinReader)
+		Eventually(session.Err).Should(Say("Starting on http://localhost:"))
+		url := getTargetUrl(session.Err)
+
+		Expect(stdinWriter.Close()).To(Succeed())
+
+		Consistently(session).ShouldNot(gexec.Exit())
+		Expect(http.Get(url + "/status")).To(HaveHTTPBody(ContainSubstring(`"state":"running"`)))
+	})
+
+	It("waits for the syslog server to close with --exit-on-eof", func() {
+		stdinReader, stdinWriter = io.Pipe()
+
+		session = runBin([]string{"--exit-on-eof", "--syslog-listen", "127.0.0.1:0"}, stdinReader)
+		Eventually(session.Err).Should(Say("Starting on http://localhost:"))
+		url := getTargetUrl(session.Err)
+
+		Expect(stdinWriter.Close()).To(Succeed())
+
+		Consistently(session).ShouldNot(gexec.Exit())
+		Expect(http.Get(url + "/status")).To(HaveHTTPBody(ContainSubstring(`"state":"running"`)))
+	})
+
+	It("copies stdin to stdout with --tee", func() {
+		stdinReader, stdinWriter = io.Pipe()
+
+		session = runBin([]string{"--tee", "--tee-match", "keep"}, stdinReader)
+		Eventually(session.Err).Should(Say("Starting on http://localhost:"))
+
+		_, _ = fmt.Fprintln(stdinWriter, "drop this line")
+		_, _ = fmt.Fprintln(stdinWriter, "keep this line")
+
+		Eventually(session.Out).Should(Say("keep this line\n"))
+		Expect(session.Out.Contents()).ToNot(ContainSubstring("drop this line"))
+	})
+
+	It("follows the files passed with --file", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "app.log")
+		Expect(os.WriteFile(path, []byte("line from a file\n"), 0o644)).To(Succeed())
+
+		session = runBin([]string{"--file", path}, nil)
+		Eventually(session.Err).Should(Say("Starting on http://localhost:"))
+
+		resp, err := http.Get(getTargetUrl(session.Err) + "/logs")
+		Expect(err).ShouldNot(HaveOccurred())
+
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Split(utils.ScanEvent)
+
+		Expect(scanner.Scan()).To(BeTrue())
+		Expect(scanner.Text()).To(MatchRegexp("data:.*line from a file"))
+	})
+
+	It("serves the logs stored in --db with --readonly", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "logs.db")
+		stdinReader, stdinWriter = io.Pipe()
+		session = runBin([]string{"--db", path, "--exit-on-eof"}, stdinReader)
+		Eventually(session.Err).Should(Say("Starting on http://localhost:"))
+		_, _ = fmt.Fprintln(stdinWriter, "saved line")
+		Expect(stdinWriter.Close()).To(Succeed())
+		Eventually(session).Should(gexec.Exit(0))
+
+		session = runBin([]string{"--db", path, "--readonly"}, nil)
+		Eventually(session.Err).Should(Say("Starting on http://localhost:"))
+		url := getTargetUrl(session.Err)
+
+		resp, err := http.Get(url + "/logs")
+		Expect(err).ShouldNot(HaveOccurred())
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Split(utils.ScanEvent)
+		Expect(scanner.Scan()).To(BeTrue())
+		Expect(scanner.Text()).To(MatchRegexp("data:.*saved line"))
+
+		Expect(http.Post(url+"/ingest", "text/plain", strings.NewReader("new line"))).
+			To(HaveHTTPStatus(http.StatusNotFound))
+	})
+
+	It("writes the logs waiting for their batch on SIGINT", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "logs.db")
+		stdinReader, stdinWriter = io.Pipe()
+		session = runBin([]string{"--db", path, "--batch-interval", "1h"}, stdinReader)
+		Eventually(session.Err).Should(Say("Starting on http://localhost:"))
+		_, _ = fmt.Fprintln(stdinWriter, "pending line")
+
+		session.Interrupt()
+		Eventually(session).Should(gexec.Exit(128 + int(syscall.SIGINT)))
+
+		session = runBin([]string{"--db", path, "--readonly"}, nil)
+		Eventually(session.Err).Should(Say("Starting on http://localhost:"))
+		resp, err := http.Get(getTargetUrl(session.Err) + "/logs")
+		Expect(err).ShouldNot(HaveOccurred())
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Split(utils.ScanEvent)
+		Expect(scanner.Scan()).To(BeTrue())
+		Expect(scanner.Text()).To(MatchRegexp("data:.*pending line"))
+	})
+
+	It("fails with --readonly on databases that are not streamlog's", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "empty.db")
+		Expect(os.WriteFile(path, nil, 0o644)).To(Succeed())
+
+		session = runBin([]string{"--db", path, "--readonly"}, nil)
+
+		Eventually(session.Err).Should(Say("is not a streamlog database"))
+		Eventually(session).Should(gexec.Exit(1))
+	})
+
+	It("writes a snapshot of the in-memory database on SIGUSR1 and on exit with --snapshot-on-exit", func() {
+		dir := GinkgoT().TempDir()
+		stdinReader, stdinWriter = io.Pipe()
+		session = runBin([]string{"--snapshot-dir", dir, "--snapshot-on-exit"}, stdinReader)
+		Eventually(session.Err).Should(Say("Starting on http://localhost:"))
+		_, _ = fmt.Fprintln(stdinWriter, "saved line")
+
+		session.Signal(syscall.SIGUSR1)
+		Eventually(session.Err).Should(Say(`Wrote snapshot .*streamlog-.*\.db`))
+
+		session.Terminate()
+		Eventually(session.Err).Should(Say(`Wrote snapshot .*streamlog-.*\.db`))
+		Eventually(session).Should(gexec.Exit(128 + int(syscall.SIGTERM)))
+
+		Expect(filepath.Glob(filepath.Join(dir, "streamlog-*.db"))).To(HaveLen(2))
+	})
+
+	It("runs the command passed after -- and exits with its status", func() {
+		session = runBin([]string{"--exit-on-eof", "--tee", "--", "/bin/sh", "-c", "echo out; echo err >&2; exit 3"}, nil)
+
+		Eventually(session.Out).Should(Say("out\n"))
+		Eventually(session.Out).Should(Say("sh exited with status 3\n"))
+		Eventually(session).Should(gexec.Exit(3))
+	})
+
 	Describe("API", func() {
 		Describe("/logs endpoint", func() {
 			It("streams events matching the lines read from stdin", func() {
@@ -116,6 +274,27 @@ var _ = Describe("Test/Integration/Streamlog", func() {
 			})
 		})
 
+		Describe("/ingest endpoint", func() {
+			It("streams the pushed logs with the lines read from stdin", func() {
+				resp, err := http.Get(targetUrl + "/logs")
+				Expect(err).ShouldNot(HaveOccurred())
+
+				scanner := bufio.NewScanner(resp.Body)
+				scanner.Split(utils.ScanEvent)
+
+				_, _ = fmt.Fprintln(stdinWriter, "line from stdin")
+				Expect(scanner.Scan()).To(BeTrue())
+				Expect(scanner.Text()).To(MatchRegexp(`data:.*line from stdin.*"source":"stdin"`))
+
+				resp, err = http.Post(targetUrl+"/ingest?source=ci", "text/plain", strings.NewReader("pushed line\n"))
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(resp).To(HaveHTTPStatus(http.StatusOK))
+
+				Expect(scanner.Scan()).To(BeTrue())
+				Expect(scanner.Text()).To(MatchRegexp(`data:.*pushed line.*"source":"ci"`))
+			})
+		})
+
 		Describe("/clients endpoint", func() {
 			It("returns a count of clients", func() {
 				Expect(http.Get(targetUrl + "/clients")).To(